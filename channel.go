@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// log codes of chat lines, the codes above 0xFF are system messages about the
+// channel in the low byte
+const (
+	CodeSay           = 0x000A
+	CodeShout         = 0x000B
+	CodeTell          = 0x000C
+	CodeParty         = 0x000E
+	CodeAlliance      = 0x000F
+	CodeLinkshell1    = 0x0010
+	CodeLinkshell2    = 0x0011
+	CodeLinkshell3    = 0x0012
+	CodeLinkshell4    = 0x0013
+	CodeLinkshell5    = 0x0014
+	CodeLinkshell6    = 0x0015
+	CodeLinkshell7    = 0x0016
+	CodeLinkshell8    = 0x0017
+	CodeFreeCompany   = 0x0018
+	CodeNoviceNetwork = 0x001B
+	CodeYell          = 0x001E
+	CodeCWLinkshell1  = 0x0025
+	CodeEcho          = 0x0038
+	CodeSystem        = 0x0039
+	CodeCWLinkshell2  = 0x0065
+	CodeCWLinkshell3  = 0x0066
+	CodeCWLinkshell4  = 0x0067
+	CodeCWLinkshell5  = 0x0068
+	CodeCWLinkshell6  = 0x0069
+	CodeCWLinkshell7  = 0x006A
+	CodeCWLinkshell8  = 0x006B
+	// CodeDuty is a duty beginning or ending.
+	CodeDuty = 0x0839
+	// CodePartyChange is a player joining, leaving or returning to the party.
+	CodePartyChange = 0x2239
+	// CodeFCMemberStatus is a free company member logging in or out.
+	CodeFCMemberStatus = 0x2245
+)
+
+// chatChannels maps the names used in the channels config to the log codes
+// of their lines.
+var chatChannels = map[string][]int64{
+	"say":       {CodeSay},
+	"shout":     {CodeShout},
+	"tell":      {CodeTell},
+	"party":     {CodeParty, CodePartyChange},
+	"alliance":  {CodeAlliance},
+	"linkshell": {CodeLinkshell1, CodeLinkshell2, CodeLinkshell3, CodeLinkshell4, CodeLinkshell5, CodeLinkshell6, CodeLinkshell7, CodeLinkshell8},
+	"fc":        {CodeFreeCompany, CodeFCMemberStatus},
+	"novice":    {CodeNoviceNetwork},
+	"yell":      {CodeYell},
+	"cwls":      {CodeCWLinkshell1, CodeCWLinkshell2, CodeCWLinkshell3, CodeCWLinkshell4, CodeCWLinkshell5, CodeCWLinkshell6, CodeCWLinkshell7, CodeCWLinkshell8},
+	"echo":      {CodeEcho},
+	"system":    {CodeSystem},
+}
+
+// channelName returns the name of the channel of the log code for logging,
+// the code in hex if it isn't one of chatChannels.
+func channelName(code int64) string {
+	for name, codes := range chatChannels {
+		if slices.Contains(codes, code) {
+			return name
+		}
+	}
+	return fmt.Sprintf("%04X", code)
+}
+
+// channelAllowed returns true if the channels allowlist is empty or includes
+// the channel of the log code.
+func channelAllowed(c Config, code int64) bool {
+	if len(c.Channels) == 0 {
+		return true
+	}
+	for _, channel := range c.Channels {
+		if slices.Contains(chatChannels[channel], code) {
+			return true
+		}
+	}
+	return false
+}
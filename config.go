@@ -0,0 +1,692 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultConfigPath = "config.yml"
+const defaultWebsocketHost = "127.0.0.1"
+const InputModeWebsocket = "websocket"
+const InputModeLogFile = "logfile"
+const defaultWebsocketPath = "MiniParse"
+const defaultDedupWindowSeconds = 5
+const defaultInitialTimeoutSeconds = 120
+const defaultShutdownTimeoutSeconds = 10
+const defaultTimestampFormat = "15:04:05"
+const defaultPushoverEmergencyRetry = 60
+const defaultPushoverEmergencyExpire = 3600
+
+// defaultReconnectCloseCodes are the websocket close codes of a server going
+// away or restarting, or the connection dropping, which are worth
+// reconnecting after.
+var defaultReconnectCloseCodes = []int{1000, 1001, 1006, 1011, 1012, 1013}
+
+var configPath = defaultConfigPath
+var config = Config{}
+var notifiers = []Notifier{}
+
+// configLock guards config and notifiers which are replaced when the config
+// file is reloaded.
+var configLock sync.RWMutex
+
+type Config struct {
+	WebsocketHost               string            `yaml:"websocket_host"`
+	WebsocketPort               int               `yaml:"websocket_port"`
+	WebsocketPath               string            `yaml:"websocket_path"`
+	WebsocketTLS                bool              `yaml:"websocket_tls"`
+	WebsocketInsecureSkipVerify bool              `yaml:"websocket_insecure_skip_verify"`
+	PushoverAppToken            string            `yaml:"pushover_app_token"`
+	PushoverUserKey             string            `yaml:"pushover_user_key"`
+	PushoverUserKeys            []string          `yaml:"pushover_user_keys"`
+	PushoverDevice              string            `yaml:"pushover_device"`
+	PushoverFormat              string            `yaml:"pushover_format"`
+	PushoverCustomSounds        []string          `yaml:"pushover_custom_sounds"`
+	PushbulletAccessToken       string            `yaml:"pushbullet_access_token"`
+	PushbulletDeviceIden        string            `yaml:"pushbullet_device_iden"`
+	DiscordWebhookURL           string            `yaml:"discord_webhook_url"`
+	DiscordEmbedColor           int               `yaml:"discord_embed_color"`
+	SlackWebhookURL             string            `yaml:"slack_webhook_url"`
+	TelegramBotToken            string            `yaml:"telegram_bot_token"`
+	TelegramChatID              string            `yaml:"telegram_chat_id"`
+	NtfyServer                  string            `yaml:"ntfy_server"`
+	NtfyTopic                   string            `yaml:"ntfy_topic"`
+	NtfyToken                   string            `yaml:"ntfy_token"`
+	GotifyServer                string            `yaml:"gotify_server"`
+	GotifyAppToken              string            `yaml:"gotify_app_token"`
+	MatrixHomeserver            string            `yaml:"matrix_homeserver"`
+	MatrixAccessToken           string            `yaml:"matrix_access_token"`
+	MatrixRoomID                string            `yaml:"matrix_room_id"`
+	MqttBroker                  string            `yaml:"mqtt_broker"`
+	MqttTopic                   string            `yaml:"mqtt_topic"`
+	MqttUsername                string            `yaml:"mqtt_username"`
+	MqttPassword                string            `yaml:"mqtt_password"`
+	WebhookURL                  string            `yaml:"webhook_url"`
+	WebhookTemplate             string            `yaml:"webhook_template"`
+	WebhookContentType          string            `yaml:"webhook_content_type"`
+	WebhookSecret               string            `yaml:"webhook_secret"`
+	SmtpHost                    string            `yaml:"smtp_host"`
+	SmtpPort                    int               `yaml:"smtp_port"`
+	SmtpUser                    string            `yaml:"smtp_user"`
+	SmtpPassword                string            `yaml:"smtp_password"`
+	SmtpFrom                    string            `yaml:"smtp_from"`
+	SmtpTo                      []string          `yaml:"smtp_to"`
+	DesktopNotifications        bool              `yaml:"desktop_notifications"`
+	NotifyOnFill                bool              `yaml:"notify_on_fill"`
+	NotifyOnDisband             bool              `yaml:"notify_on_disband"`
+	NotifyOnJoin                bool              `yaml:"notify_on_join"`
+	NotifyOnLeave               bool              `yaml:"notify_on_leave"`
+	NotifyOnDutyPop             bool              `yaml:"notify_on_duty_pop"`
+	NotifyOnTell                bool              `yaml:"notify_on_tell"`
+	TellSenders                 []string          `yaml:"tell_senders"`
+	NotifyPlayers               []string          `yaml:"notify_players"`
+	IgnorePlayers               []string          `yaml:"ignore_players"`
+	SelfCharacterName           string            `yaml:"self_character_name"`
+	Sounds                      map[string]string `yaml:"sounds"`
+	TitlePrefixes               map[string]string `yaml:"title_prefixes"`
+	TitleTemplates              map[string]string `yaml:"title_templates"`
+	MessageTemplates            map[string]string `yaml:"message_templates"`
+	MessagePrefixes             map[string]string `yaml:"message_prefixes"`
+	URLs                        map[string]string `yaml:"urls"`
+	URLTitles                   map[string]string `yaml:"url_titles"`
+	Attachments                 map[string]string `yaml:"attachments"`
+	Priorities                  map[string]int    `yaml:"priorities"`
+	Cooldowns                   map[string]int    `yaml:"cooldowns"`
+	PushoverEmergencyRetry      int               `yaml:"pushover_emergency_retry"`
+	PushoverEmergencyExpire     int               `yaml:"pushover_emergency_expire"`
+	QuietHoursStart             string            `yaml:"quiet_hours_start"`
+	QuietHoursEnd               string            `yaml:"quiet_hours_end"`
+	QuietHoursExempt            []string          `yaml:"quiet_hours_exempt"`
+	SuppressInDuty              []string          `yaml:"suppress_in_duty"`
+	DedupWindowSeconds          *int              `yaml:"dedup_window_seconds"`
+	InputMode                   string            `yaml:"input_mode"`
+	LogFilePath                 string            `yaml:"log_file_path"`
+	ClientLanguage              string            `yaml:"client_language"`
+	Triggers                    []Trigger         `yaml:"triggers"`
+	NotifyOnInvite              bool              `yaml:"notify_on_invite"`
+	NotifyOnKick                bool              `yaml:"notify_on_kick"`
+	NotifyOnLeaderChange        bool              `yaml:"notify_on_leader_change"`
+	NotifyOnTrade               bool              `yaml:"notify_on_trade"`
+	NotifyOnVenture             bool              `yaml:"notify_on_venture"`
+	NotifyOnFCLogin             bool              `yaml:"notify_on_fc_login"`
+	NotifyOnPartyDeath          bool              `yaml:"notify_on_party_death"`
+	NotifyOnNoviceNetwork       bool              `yaml:"notify_on_novice_network"`
+	NoviceNetworkKeywords       []string          `yaml:"novice_network_keywords"`
+	NotifyOnReadyCheck          bool              `yaml:"notify_on_ready_check"`
+	NotifyOnFCAnnouncement      bool              `yaml:"notify_on_fc_announcement"`
+	FCAnnouncementKeywords      []string          `yaml:"fc_announcement_keywords"`
+	DigestSeconds               int               `yaml:"digest_seconds"`
+	RejoinWindowSeconds         int               `yaml:"rejoin_window_seconds"`
+	DigestMaxEvents             int               `yaml:"digest_max_events"`
+	LogLevel                    string            `yaml:"log_level"`
+	LogFormat                   string            `yaml:"log_format"`
+	HealthPort                  int               `yaml:"health_port"`
+	MetricsEnabled              bool              `yaml:"metrics_enabled"`
+	MetricsPort                 int               `yaml:"metrics_port"`
+	NotificationLog             string            `yaml:"notification_log"`
+	NotificationLogSync         bool              `yaml:"notification_log_sync"`
+	WebsocketSources            []WebsocketSource `yaml:"websocket_sources"`
+	IncludeTimestamp            bool              `yaml:"include_timestamp"`
+	TimestampFormat             string            `yaml:"timestamp_format"`
+	Timezone                    string            `yaml:"timezone"`
+	OutputMode                  string            `yaml:"output_mode"`
+	Channels                    []string          `yaml:"channels"`
+	WatchCodes                  []int64           `yaml:"watch_codes"`
+	SubscribeEvents             []string          `yaml:"subscribe_events"`
+	SoundOnce                   bool              `yaml:"sound_once"`
+	HTTPProxy                   string            `yaml:"http_proxy"`
+	HomeWorld                   string            `yaml:"home_world"`
+	HomeWorldOnly               bool              `yaml:"home_world_only"`
+	StaleTimeoutSeconds         int               `yaml:"stale_timeout_seconds"`
+	ReconnectCloseCodes         []int             `yaml:"reconnect_close_codes"`
+	InitialTimeoutSeconds       *int              `yaml:"initial_message_timeout_seconds"`
+	ShutdownTimeoutSeconds      *int              `yaml:"shutdown_timeout_seconds"`
+	ConfigDir                   string            `yaml:"config_dir"`
+	BackendsEnabled             map[string]bool   `yaml:"backends_enabled"`
+
+	// how failed sends are retried, RetryPolicies is keyed by backend name
+	NotifyRetryMax    *int                         `yaml:"notify_retry_max"`
+	NotifyRetryBaseMs int                          `yaml:"notify_retry_base_ms"`
+	NotifyRetryJitter *float64                     `yaml:"notify_retry_jitter"`
+	RetryPolicies     map[string]RetryPolicyConfig `yaml:"retry_policies"`
+
+	// the misspelled keys are still read so that older configs keep working
+	DeprecatedNotifyOnFill    *bool `yaml:"notifiy_on_fill"`
+	DeprecatedNotifyOnDisband *bool `yaml:"notifiy_on_disband"`
+
+	// unknownKeys are keys in the config file that don't match any field.
+	unknownKeys []string
+	// webhookTemplate is the parsed WebhookTemplate.
+	webhookTemplate *template.Template
+	// titleTemplates and messageTemplates are the parsed TitleTemplates and
+	// MessageTemplates.
+	titleTemplates   map[string]*template.Template
+	messageTemplates map[string]*template.Template
+	// location is the loaded Timezone.
+	location *time.Location
+	// proxy picks the proxy for a URL from HTTPProxy, nil when it isn't set.
+	proxy func(*url.URL) (*url.URL, error)
+}
+
+// timeLocation returns the loaded timezone, the local timezone for a config
+// that wasn't read from a file.
+func (c Config) timeLocation() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// lang returns the strings of the client language, English when it is unset
+// or unknown.
+func (c Config) lang() ClientStrings {
+	if lang, ok := clientStrings[c.ClientLanguage]; ok {
+		return lang
+	}
+	return clientStrings[defaultClientLanguage]
+}
+
+// readConfig reads the config file and environment overrides, applying
+// defaults for anything left unset.
+func readConfig() (Config, error) {
+	// the config file is optional when settings come from the environment
+	rawConfig, err := os.ReadFile(configPath)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && hasEnvOverrides()) {
+		return Config{}, err
+	}
+	newConfig := Config{}
+	if err := yaml.Unmarshal(rawConfig, &newConfig); err != nil {
+		return Config{}, err
+	}
+	newConfig.unknownKeys = unknownConfigKeys(rawConfig)
+	keys := configKeys(rawConfig)
+	configFiles, err := configDirFiles(newConfig.ConfigDir)
+	if err != nil {
+		return Config{}, err
+	}
+	for _, path := range configFiles {
+		rawConfig, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := mergeConfig(&newConfig, rawConfig); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		maps.Copy(keys, configKeys(rawConfig))
+		for _, key := range unknownConfigKeys(rawConfig) {
+			newConfig.unknownKeys = append(newConfig.unknownKeys, fmt.Sprintf("%s (in %s)", key, filepath.Base(path)))
+		}
+	}
+	if err := applyEnvOverrides(&newConfig); err != nil {
+		return Config{}, err
+	}
+	// the misspelled keys only apply when the correct key isn't set anywhere
+	if newConfig.DeprecatedNotifyOnFill != nil && !isConfigKeySet(keys, "notify_on_fill") {
+		newConfig.NotifyOnFill = *newConfig.DeprecatedNotifyOnFill
+	}
+	if newConfig.DeprecatedNotifyOnDisband != nil && !isConfigKeySet(keys, "notify_on_disband") {
+		newConfig.NotifyOnDisband = *newConfig.DeprecatedNotifyOnDisband
+	}
+	if err := checkWebsocketHost("websocket_host", newConfig.WebsocketHost); err != nil {
+		return Config{}, err
+	}
+	for i := range newConfig.WebsocketSources {
+		if err := checkWebsocketHost(fmt.Sprintf("websocket_sources[%d].host", i), newConfig.WebsocketSources[i].Host); err != nil {
+			return Config{}, err
+		}
+	}
+	if newConfig.LogLevel == "" {
+		newConfig.LogLevel = "info"
+	}
+	if newConfig.LogFormat == "" {
+		newConfig.LogFormat = LogFormatText
+	}
+	if newConfig.TimestampFormat == "" {
+		newConfig.TimestampFormat = defaultTimestampFormat
+	}
+	if newConfig.ClientLanguage == "" {
+		newConfig.ClientLanguage = defaultClientLanguage
+	}
+	if newConfig.InputMode == "" {
+		newConfig.InputMode = InputModeWebsocket
+	}
+	if newConfig.WebsocketHost == "" {
+		newConfig.WebsocketHost = defaultWebsocketHost
+	}
+	if newConfig.WebsocketPath == "" {
+		newConfig.WebsocketPath = defaultWebsocketPath
+	}
+	for i := range newConfig.WebsocketSources {
+		source := &newConfig.WebsocketSources[i]
+		if source.Host == "" {
+			source.Host = defaultWebsocketHost
+		}
+		if source.Path == "" {
+			source.Path = defaultWebsocketPath
+		}
+	}
+	if newConfig.NtfyServer == "" {
+		newConfig.NtfyServer = defaultNtfyServer
+	}
+	if newConfig.DedupWindowSeconds == nil {
+		dedupWindowSeconds := defaultDedupWindowSeconds
+		newConfig.DedupWindowSeconds = &dedupWindowSeconds
+	}
+	if newConfig.ReconnectCloseCodes == nil {
+		newConfig.ReconnectCloseCodes = defaultReconnectCloseCodes
+	}
+	if newConfig.ShutdownTimeoutSeconds == nil {
+		shutdownTimeoutSeconds := defaultShutdownTimeoutSeconds
+		newConfig.ShutdownTimeoutSeconds = &shutdownTimeoutSeconds
+	}
+	if newConfig.InitialTimeoutSeconds == nil {
+		initialTimeoutSeconds := defaultInitialTimeoutSeconds
+		newConfig.InitialTimeoutSeconds = &initialTimeoutSeconds
+	}
+	if newConfig.NotifyRetryMax == nil {
+		notifyRetryMax := defaultNotifyRetryMax
+		newConfig.NotifyRetryMax = &notifyRetryMax
+	}
+	if newConfig.NotifyRetryBaseMs == 0 {
+		newConfig.NotifyRetryBaseMs = defaultNotifyRetryBaseMs
+	}
+	if newConfig.NotifyRetryJitter == nil {
+		notifyRetryJitter := defaultNotifyRetryJitter
+		newConfig.NotifyRetryJitter = &notifyRetryJitter
+	}
+	if newConfig.MetricsPort == 0 {
+		newConfig.MetricsPort = defaultMetricsPort
+	}
+	if newConfig.DigestMaxEvents == 0 {
+		newConfig.DigestMaxEvents = defaultDigestMaxEvents
+	}
+	if newConfig.PushoverEmergencyRetry == 0 {
+		newConfig.PushoverEmergencyRetry = defaultPushoverEmergencyRetry
+	}
+	if newConfig.PushoverEmergencyExpire == 0 {
+		newConfig.PushoverEmergencyExpire = defaultPushoverEmergencyExpire
+	}
+	for event, path := range newConfig.Attachments {
+		newConfig.Attachments[event] = resolveConfigPath(path)
+	}
+	newConfig.NotificationLog = resolveConfigPath(newConfig.NotificationLog)
+	if newConfig.SmtpPort == 0 {
+		newConfig.SmtpPort = defaultSmtpPort
+	}
+	if newConfig.WebhookContentType == "" {
+		newConfig.WebhookContentType = defaultWebhookContentType
+	}
+	if err := compileTriggers(newConfig.Triggers); err != nil {
+		return Config{}, err
+	}
+	if newConfig.HTTPProxy != "" {
+		if proxyURL, err := url.Parse(newConfig.HTTPProxy); err != nil || proxyURL.Host == "" {
+			return Config{}, fmt.Errorf("http_proxy must be a URL such as http://proxy:3128 or socks5://proxy:1080, got %s", newConfig.HTTPProxy)
+		}
+		// hosts in NO_PROXY, and localhost, are still connected to directly
+		newConfig.proxy = (&httpproxy.Config{
+			HTTPProxy:  newConfig.HTTPProxy,
+			HTTPSProxy: newConfig.HTTPProxy,
+			NoProxy:    httpproxy.FromEnvironment().NoProxy,
+		}).ProxyFunc()
+	}
+	newConfig.location = time.Local
+	if newConfig.Timezone != "" {
+		if newConfig.location, err = time.LoadLocation(newConfig.Timezone); err != nil {
+			return Config{}, fmt.Errorf("timezone %s is not a valid IANA time zone name: %w", newConfig.Timezone, err)
+		}
+	}
+	if newConfig.webhookTemplate, err = parseWebhookTemplate(newConfig.WebhookTemplate); err != nil {
+		return Config{}, fmt.Errorf("invalid webhook_template: %w", err)
+	}
+	if newConfig.titleTemplates, err = parseEventTemplates(newConfig.TitleTemplates); err != nil {
+		return Config{}, fmt.Errorf("invalid title_templates: %w", err)
+	}
+	if newConfig.messageTemplates, err = parseEventTemplates(newConfig.MessageTemplates); err != nil {
+		return Config{}, fmt.Errorf("invalid message_templates: %w", err)
+	}
+	return newConfig, nil
+}
+
+// resolveConfigPath returns the path of a file or directory named in the
+// config, relative paths are relative to the config file.
+func resolveConfigPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath), path)
+}
+
+// configDirFiles returns the .yml and .yaml files in the config directory in
+// the order they are merged, which is by name.
+func configDirFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(resolveConfigPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			out = append(out, filepath.Join(resolveConfigPath(dir), entry.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// mergeConfig reads the YAML over the config, lists in it are appended to
+// the config's rather than replacing them. Maps are merged by the YAML
+// decoder already.
+func mergeConfig(c *Config, rawConfig []byte) error {
+	before := reflect.ValueOf(*c)
+	if err := yaml.Unmarshal(rawConfig, c); err != nil {
+		return err
+	}
+	after := reflect.ValueOf(c).Elem()
+	for i := 0; i < after.NumField(); i++ {
+		field := after.Field(i)
+		if !after.Type().Field(i).IsExported() || field.Kind() != reflect.Slice {
+			continue
+		}
+		// the decoder only replaces the slice when the YAML sets it
+		if previous := before.Field(i); previous.Len() > 0 && field.Pointer() != previous.Pointer() {
+			field.Set(reflect.AppendSlice(previous, field))
+		}
+	}
+	return nil
+}
+
+// configKeys returns the top level keys in the config file.
+func configKeys(rawConfig []byte) map[string]bool {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(rawConfig, &raw); err != nil {
+		return map[string]bool{}
+	}
+	keys := map[string]bool{}
+	for key := range raw {
+		keys[key] = true
+	}
+	return keys
+}
+
+// isConfigKeySet returns true if the key is in one of the config files or its
+// environment variable is set.
+func isConfigKeySet(keys map[string]bool, key string) bool {
+	_, ok := os.LookupEnv(envName(key))
+	return ok || keys[key]
+}
+
+// unknownConfigKeys returns the top level keys in the config file that don't
+// match any config field.
+func unknownConfigKeys(rawConfig []byte) []string {
+	configType := reflect.TypeOf(Config{})
+	known := map[string]bool{}
+	for i := 0; i < configType.NumField(); i++ {
+		known[strings.Split(configType.Field(i).Tag.Get("yaml"), ",")[0]] = true
+	}
+	out := []string{}
+	for key := range configKeys(rawConfig) {
+		if !known[key] {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hasNotifyEnabled(c Config) bool {
+	return c.NotifyOnFill || c.NotifyOnDisband || c.NotifyOnJoin || c.NotifyOnLeave ||
+		c.NotifyOnDutyPop || c.NotifyOnTell || c.NotifyOnInvite ||
+		c.NotifyOnKick || c.NotifyOnLeaderChange || c.NotifyOnTrade ||
+		c.NotifyOnVenture || c.NotifyOnFCLogin || c.NotifyOnPartyDeath ||
+		c.NotifyOnNoviceNetwork || c.NotifyOnReadyCheck ||
+		c.NotifyOnFCAnnouncement || len(c.Triggers) > 0
+}
+
+// validateConfig checks the config for anything that would stop notifications
+// from being sent, returning every problem found combined into one error.
+func validateConfig(c Config) error {
+	errs := []error{}
+	switch c.InputMode {
+	case InputModeWebsocket:
+		if len(c.WebsocketSources) == 0 && (c.WebsocketPort <= 0 || c.WebsocketPort > 65535) {
+			errs = append(errs, fmt.Errorf("websocket_port must be between 1 and 65535, got %d", c.WebsocketPort))
+		}
+		for i, source := range c.WebsocketSources {
+			if source.Port <= 0 || source.Port > 65535 {
+				errs = append(errs, fmt.Errorf("websocket_sources[%d].port must be between 1 and 65535, got %d", i, source.Port))
+			}
+		}
+	case InputModeLogFile:
+		if c.LogFilePath == "" {
+			errs = append(errs, errors.New("log_file_path is required when input_mode is logfile"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("input_mode must be %s or %s, got %s", InputModeWebsocket, InputModeLogFile, c.InputMode))
+	}
+	if c.OutputMode != "" && c.OutputMode != OutputModeStdoutJSON {
+		errs = append(errs, fmt.Errorf("output_mode must be empty or %s, got %s", OutputModeStdoutJSON, c.OutputMode))
+	}
+	if c.MetricsEnabled && (c.MetricsPort < 1 || c.MetricsPort > 65535) {
+		errs = append(errs, fmt.Errorf("metrics_port must be between 1 and 65535, got %d", c.MetricsPort))
+	}
+	if c.RejoinWindowSeconds < 0 {
+		errs = append(errs, fmt.Errorf("rejoin_window_seconds must not be negative, got %d", c.RejoinWindowSeconds))
+	}
+	if *c.ShutdownTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout_seconds must not be negative, got %d", *c.ShutdownTimeoutSeconds))
+	}
+	if *c.InitialTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("initial_message_timeout_seconds must not be negative, got %d", *c.InitialTimeoutSeconds))
+	}
+	for _, code := range c.ReconnectCloseCodes {
+		if code < 1000 || code > 4999 {
+			errs = append(errs, fmt.Errorf("reconnect_close_codes must be between 1000 and 4999, got %d", code))
+		}
+	}
+	if c.StaleTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("stale_timeout_seconds must not be negative, got %d", c.StaleTimeoutSeconds))
+	}
+	if *c.NotifyRetryMax < 0 {
+		errs = append(errs, fmt.Errorf("notify_retry_max must not be negative, got %d", *c.NotifyRetryMax))
+	}
+	if c.NotifyRetryBaseMs < 0 {
+		errs = append(errs, fmt.Errorf("notify_retry_base_ms must not be negative, got %d", c.NotifyRetryBaseMs))
+	}
+	if *c.NotifyRetryJitter < 0 || *c.NotifyRetryJitter > 1 {
+		errs = append(errs, fmt.Errorf("notify_retry_jitter must be between 0 and 1, got %g", *c.NotifyRetryJitter))
+	}
+	for backend, policy := range c.RetryPolicies {
+		if policy.Max != nil && *policy.Max < 0 {
+			errs = append(errs, fmt.Errorf("retry_policies.%s.max must not be negative, got %d", backend, *policy.Max))
+		}
+		if policy.BaseMs < 0 {
+			errs = append(errs, fmt.Errorf("retry_policies.%s.base_ms must not be negative, got %d", backend, policy.BaseMs))
+		}
+		if policy.Jitter != nil && (*policy.Jitter < 0 || *policy.Jitter > 1) {
+			errs = append(errs, fmt.Errorf("retry_policies.%s.jitter must be between 0 and 1, got %g", backend, *policy.Jitter))
+		}
+	}
+	if c.HealthPort < 0 || c.HealthPort > 65535 {
+		errs = append(errs, fmt.Errorf("health_port must be between 0 and 65535, got %d", c.HealthPort))
+	}
+	userKeys := pushoverUserKeys(c)
+	if c.PushoverAppToken != "" && len(userKeys) == 0 {
+		errs = append(errs, errors.New("pushover_user_key or pushover_user_keys is required when pushover_app_token is set"))
+	}
+	if len(userKeys) > 0 && c.PushoverAppToken == "" {
+		errs = append(errs, errors.New("pushover_app_token is required when pushover_user_key or pushover_user_keys is set"))
+	}
+	if strings.HasPrefix(c.PushoverAppToken, "<") || strings.HasPrefix(c.PushoverUserKey, "<") {
+		errs = append(errs, errors.New("pushover_app_token and pushover_user_key must be replaced with your own from pushover.net"))
+	}
+	for backend := range c.BackendsEnabled {
+		if !slices.Contains(backendNames, backend) {
+			errs = append(errs, fmt.Errorf("backends_enabled has unknown backend %s", backend))
+		}
+	}
+	if len(c.SmtpTo) > 0 && (c.SmtpHost == "" || c.SmtpFrom == "") {
+		errs = append(errs, errors.New("smtp_host and smtp_from are required when smtp_to is set"))
+	}
+	if c.SmtpPort < 1 || c.SmtpPort > 65535 {
+		errs = append(errs, fmt.Errorf("smtp_port must be between 1 and 65535, got %d", c.SmtpPort))
+	}
+	if len(buildNotifiers(c)) == 0 {
+		errs = append(errs, errors.New("no notification backend is configured, set pushover_app_token and pushover_user_key or another backend"))
+	}
+	if (c.QuietHoursStart == "") != (c.QuietHoursEnd == "") {
+		errs = append(errs, errors.New("quiet_hours_start and quiet_hours_end must be set together"))
+	}
+	for _, clockTime := range []string{c.QuietHoursStart, c.QuietHoursEnd} {
+		if _, err := parseClockTime(clockTime); clockTime != "" && err != nil {
+			errs = append(errs, fmt.Errorf("invalid quiet hours: %w", err))
+		}
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level must be debug, info, warn or error, got %s", c.LogLevel))
+	}
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		errs = append(errs, fmt.Errorf("log_format must be %s or %s, got %s", LogFormatText, LogFormatJSON, c.LogFormat))
+	}
+	if _, ok := clientStrings[c.ClientLanguage]; !ok {
+		errs = append(errs, fmt.Errorf("client_language must be one of en, fr, de or ja, got %s", c.ClientLanguage))
+	}
+	for event, priority := range c.Priorities {
+		if priority < -2 || priority > 2 {
+			errs = append(errs, fmt.Errorf("priorities.%s must be between -2 and 2, got %d", event, priority))
+		}
+	}
+	for event, cooldown := range c.Cooldowns {
+		if cooldown < 0 {
+			errs = append(errs, fmt.Errorf("cooldowns for %s must not be negative, got %d", event, cooldown))
+		}
+	}
+	for event, path := range c.Attachments {
+		if info, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("attachments for %s: %w", event, err))
+		} else if info.Size() > pushoverAttachmentLimit {
+			errs = append(errs, fmt.Errorf("attachments for %s must be at most %d bytes, got %d", event, pushoverAttachmentLimit, info.Size()))
+		}
+	}
+	for _, channel := range c.Channels {
+		if _, ok := chatChannels[channel]; !ok {
+			errs = append(errs, fmt.Errorf("channels has unknown channel %s", channel))
+		}
+	}
+	if c.HomeWorldOnly && c.HomeWorld == "" {
+		errs = append(errs, errors.New("home_world is required when home_world_only is set"))
+	}
+	switch c.PushoverFormat {
+	case PushoverFormatPlain, PushoverFormatHTML, PushoverFormatMonospace:
+	default:
+		errs = append(errs, fmt.Errorf("pushover_format must be empty, %s or %s, got %q", PushoverFormatHTML, PushoverFormatMonospace, c.PushoverFormat))
+	}
+	if c.PushoverEmergencyRetry < 30 {
+		errs = append(errs, fmt.Errorf("pushover_emergency_retry must be at least 30 seconds, got %d", c.PushoverEmergencyRetry))
+	}
+	if c.PushoverEmergencyExpire > 10800 {
+		errs = append(errs, fmt.Errorf("pushover_emergency_expire must be at most 10800 seconds, got %d", c.PushoverEmergencyExpire))
+	}
+	for i, trigger := range c.Triggers {
+		if trigger.Pattern == "" || trigger.Title == "" {
+			errs = append(errs, fmt.Errorf("trigger %d must have a pattern and a title", i+1))
+		}
+		if err := checkPushoverSound(trigger.Sound, c.PushoverCustomSounds); err != nil {
+			errs = append(errs, fmt.Errorf("trigger %d sound: %w", i+1, err))
+		}
+	}
+	for event, sound := range c.Sounds {
+		if err := checkPushoverSound(sound, c.PushoverCustomSounds); err != nil {
+			errs = append(errs, fmt.Errorf("sounds for %s: %w", event, err))
+		}
+	}
+	if !hasNotifyEnabled(c) {
+		errs = append(errs, errors.New("no notify_on_* option is enabled and there are no triggers so nothing will ever be sent"))
+	}
+	return errors.Join(errs...)
+}
+
+// configWarnings returns problems with the config that don't stop it from
+// being used.
+func configWarnings(c Config) []string {
+	out := []string{}
+	for _, key := range c.unknownKeys {
+		out = append(out, fmt.Sprintf("Unknown config key %s will be ignored.", key))
+	}
+	if c.DeprecatedNotifyOnFill != nil {
+		out = append(out, "Config key notifiy_on_fill is deprecated, rename it to notify_on_fill.")
+	}
+	if c.DeprecatedNotifyOnDisband != nil {
+		out = append(out, "Config key notifiy_on_disband is deprecated, rename it to notify_on_disband.")
+	}
+	return out
+}
+
+// checkConfigFile prints every problem with the config, for -validate,
+// returning false if there are any. Unknown keys count as problems as they're
+// usually typos.
+func checkConfigFile() bool {
+	newConfig, err := readConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, err)
+		return false
+	}
+	ok := len(newConfig.unknownKeys) == 0
+	if err := validateConfig(newConfig); err != nil {
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, problem)
+		}
+		ok = false
+	}
+	for _, warning := range configWarnings(newConfig) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, warning)
+	}
+	if ok {
+		fmt.Printf("%s is valid.\n", configPath)
+	}
+	return ok
+}
+
+// loadConfig reads and validates the config and builds the notifiers from
+// it. The current config is only replaced once the new one has been fully
+// read, so an error leaves the last good config in place.
+func loadConfig() error {
+	newConfig, err := readConfig()
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(newConfig); err != nil {
+		return err
+	}
+	configureLogging(newConfig)
+	for _, warning := range configWarnings(newConfig) {
+		slog.Warn(warning)
+	}
+
+	configLock.Lock()
+	defer configLock.Unlock()
+	config = newConfig
+	notifiers = buildNotifiers(config)
+	return nil
+}
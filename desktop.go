@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gen2brain/beeep"
+)
+
+// DesktopNotifier raises notifications on the local desktop. On headless
+// machines there is nothing to show them on so Send returns an error which
+// is logged rather than treated as fatal.
+type DesktopNotifier struct{}
+
+func (n *DesktopNotifier) Name() string {
+	return "desktop"
+}
+
+func (n *DesktopNotifier) Send(ctx context.Context, notification *Notification) error {
+	if err := beeep.Notify(notification.Title, notification.Message, ""); err != nil {
+		return fmt.Errorf("desktop: is a display available? %w", err)
+	}
+	return nil
+}
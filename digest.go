@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const EventDigest = "digest"
+const defaultDigestMaxEvents = 10
+
+// DigestBuffer collects notifications and sends them combined as a single
+// notification once the digest window has elapsed or enough have built up.
+type DigestBuffer struct {
+	lock    sync.Mutex
+	pending []*Notification
+	timer   *time.Timer
+}
+
+var digest = &DigestBuffer{}
+
+// Add buffers the notification, starting the digest window if this is the
+// first one.
+func (d *DigestBuffer) Add(notification *Notification, window time.Duration, maxEvents int) {
+	d.lock.Lock()
+	d.pending = append(d.pending, notification)
+	full := len(d.pending) >= maxEvents
+	if !full && d.timer == nil {
+		d.timer = time.AfterFunc(window, func() { d.Flush() })
+	}
+	d.lock.Unlock()
+	if full {
+		d.Flush()
+	}
+}
+
+// Flush sends everything buffered, a single notification is sent as is.
+func (d *DigestBuffer) Flush() {
+	d.lock.Lock()
+	pending := d.pending
+	d.pending = nil
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.lock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	notification := pending[0]
+	if len(pending) > 1 {
+		notification = combineNotifications(pending)
+	}
+	configLock.RLock()
+	currentNotifiers := notifiers
+	configLock.RUnlock()
+	queueNotification(currentNotifiers, notification)
+}
+
+// combineNotifications builds a digest notification with a summary title,
+// e.g. "3 players joined, 1 left.", and the messages one per line.
+func combineNotifications(notifications []*Notification) *Notification {
+	counts := map[string]int{}
+	titles := map[string]string{}
+	order := []string{}
+	messages := []string{}
+	combined := &Notification{Type: EventDigest, Sound: "none"}
+	for _, notification := range notifications {
+		if counts[notification.Type] == 0 {
+			order = append(order, notification.Type)
+			titles[notification.Type] = notification.Title
+		}
+		counts[notification.Type]++
+		messages = append(messages, notification.Message)
+		if combined.Sound == "none" && notification.Sound != "none" {
+			combined.Sound = notification.Sound
+		}
+		combined.Priority = max(combined.Priority, notification.Priority)
+	}
+	summary := []string{}
+	// leaves are "N left" only straight after the joins, "3 players joined, 1 left"
+	for i, event := range order {
+		count := counts[event]
+		switch {
+		case event == EventJoin && count == 1:
+			summary = append(summary, "1 player joined")
+		case event == EventJoin:
+			summary = append(summary, fmt.Sprintf("%d players joined", count))
+		case event == EventLeave && i > 0 && order[i-1] == EventJoin:
+			summary = append(summary, fmt.Sprintf("%d left", count))
+		case event == EventLeave && count == 1:
+			summary = append(summary, "1 player left")
+		case event == EventLeave:
+			summary = append(summary, fmt.Sprintf("%d players left", count))
+		case count == 1:
+			summary = append(summary, titles[event])
+		default:
+			summary = append(summary, fmt.Sprintf("%s (x%d)", titles[event], count))
+		}
+	}
+	combined.Title = strings.Join(summary, ", ") + "."
+	combined.Message = strings.Join(messages, "\n")
+	return combined
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestCombineNotificationsTitle(t *testing.T) {
+	join := &Notification{Type: EventJoin, Title: "Player Joined Your Party", Sound: "none"}
+	leave := &Notification{Type: EventLeave, Title: "Player Left Your Party", Sound: "none"}
+	tests := []struct {
+		name          string
+		notifications []*Notification
+		want          string
+	}{
+		{"joins and a leave", []*Notification{join, join, leave}, "2 players joined, 1 left."},
+		{"one leave", []*Notification{leave}, "1 player left."},
+		{"leaves only", []*Notification{leave, leave}, "2 players left."},
+		{"leave before joins", []*Notification{leave, join, join}, "1 player left, 2 players joined."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if title := combineNotifications(test.notifications).Title; title != test.want {
+				t.Errorf("expected %q, got %q", test.want, title)
+			}
+		})
+	}
+}
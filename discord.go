@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+const discordTitleLimit = 256
+const discordDescriptionLimit = 4096
+
+type DiscordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Color       int    `json:"color,omitempty"`
+}
+
+type DiscordMessage struct {
+	Embeds []DiscordEmbed `json:"embeds"`
+}
+
+type DiscordNotifier struct {
+	WebhookURL string
+	EmbedColor int
+}
+
+func (n *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+func (n *DiscordNotifier) Send(ctx context.Context, notification *Notification) error {
+	data := DiscordMessage{
+		Embeds: []DiscordEmbed{{
+			Title:       truncateString(notification.Title, discordTitleLimit),
+			Description: truncateString(notification.Message, discordDescriptionLimit),
+			Color:       n.EmbedColor,
+		}},
+	}
+	resp, err := postJSON(ctx, httpClient, n.WebhookURL, data)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	return nil
+}
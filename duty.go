@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// dutyMaxLength is how long after a duty began it is assumed to be over, as
+// nothing is logged when a duty is abandoned.
+const dutyMaxLength = 2 * time.Hour
+
+// dutyStarted maps a websocket source name to when the duty its player is in
+// began.
+var dutyStarted = map[string]time.Time{}
+var dutyLock sync.Mutex
+
+// updateDutyState tracks whether the player of the log line's source is in a
+// duty from the lines logged as duties begin and end.
+func updateDutyState(c Config, logLine LogLine) {
+	if logLine.Code != CodeDuty {
+		return
+	}
+	lang := c.lang()
+	dutyLock.Lock()
+	defer dutyLock.Unlock()
+	if match := lang.DutyBegun.FindStringSubmatch(logLine.Line); match != nil {
+		slog.Debug("Entered duty", "duty", match[1], "source", logLine.Source)
+		dutyStarted[logLine.Source] = time.Now()
+	} else if match := lang.DutyEnded.FindStringSubmatch(logLine.Line); match != nil {
+		slog.Debug("Left duty", "duty", match[1], "source", logLine.Source)
+		delete(dutyStarted, logLine.Source)
+	}
+}
+
+// suppressedInDuty returns true, logging why, if the notification's type is
+// in suppress_in_duty and the player of the source is in a duty.
+func suppressedInDuty(c Config, notification *Notification, source string) bool {
+	if !slices.Contains(c.SuppressInDuty, notification.Type) {
+		return false
+	}
+	dutyLock.Lock()
+	started, ok := dutyStarted[source]
+	dutyLock.Unlock()
+	if !ok || time.Since(started) > dutyMaxLength {
+		return false
+	}
+	slog.Debug("Suppressed notification while in a duty", "type", notification.Type, "title", notification.Title)
+	return true
+}
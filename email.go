@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSmtpPort = 587
+
+// smtpImplicitTLSPort is the submissions port, connections to it are TLS
+// from the start instead of upgraded with STARTTLS.
+const smtpImplicitTLSPort = 465
+
+type EmailNotifier struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Send emails the notification with the title as the subject. The connection
+// is upgraded with STARTTLS when the server supports it, authenticating is
+// refused over a connection that isn't encrypted.
+func (n *EmailNotifier) Send(ctx context.Context, notification *Notification) error {
+	if err := n.send(ctx, notification); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+func (n *EmailNotifier) send(ctx context.Context, notification *Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+	address := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+	tlsConfig := &tls.Config{ServerName: n.Host}
+	var conn net.Conn
+	var err error
+	if n.Port == smtpImplicitTLSPort {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if n.User != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.User, n.Password, n.Host)); err != nil {
+			return fmt.Errorf("unable to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(n.message(notification)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message returns the email for the notification, headers then the quoted
+// printable message as the body.
+func (n *EmailNotifier) message(notification *Notification) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", n.From)
+	fmt.Fprintf(&out, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Title))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	out.WriteString("MIME-Version: 1.0\r\n")
+	out.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	out.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body := quotedprintable.NewWriter(&out)
+	body.Write([]byte(strings.ReplaceAll(notification.Message, "\n", "\r\n")))
+	body.Close()
+	out.WriteString("\r\n")
+	return out.Bytes()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "XIV_"
+
+// envName returns the environment variable that overrides the config field
+// with the given yaml key, e.g. pushover_app_token is XIV_PUSHOVER_APP_TOKEN.
+func envName(yamlKey string) string {
+	return envPrefix + strings.ToUpper(yamlKey)
+}
+
+// hasEnvOverrides returns true if any config environment variable is set.
+func hasEnvOverrides() bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, envPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvOverrides sets every config field that has a matching environment
+// variable. Lists are given as comma separated values.
+func applyEnvOverrides(c *Config) error {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		yamlKey := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if yamlKey == "" || yamlKey == "-" {
+			continue
+		}
+		name := envName(yamlKey)
+		rawValue, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Pointer {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			fieldValue = fieldValue.Elem()
+		}
+		switch fieldValue.Kind() {
+		case reflect.String:
+			fieldValue.SetString(rawValue)
+		case reflect.Int:
+			intValue, err := strconv.Atoi(rawValue)
+			if err != nil {
+				return fmt.Errorf("%s must be a number: %w", name, err)
+			}
+			fieldValue.SetInt(int64(intValue))
+		case reflect.Float64:
+			floatValue, err := strconv.ParseFloat(rawValue, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number: %w", name, err)
+			}
+			fieldValue.SetFloat(floatValue)
+		case reflect.Bool:
+			boolValue, err := strconv.ParseBool(rawValue)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false: %w", name, err)
+			}
+			fieldValue.SetBool(boolValue)
+		case reflect.Slice:
+			items := []string{}
+			for _, item := range strings.Split(rawValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			switch fieldValue.Type().Elem().Kind() {
+			case reflect.String:
+				fieldValue.Set(reflect.ValueOf(items))
+			case reflect.Int, reflect.Int64:
+				numbers := reflect.MakeSlice(fieldValue.Type(), 0, len(items))
+				for _, item := range items {
+					// base 0 so that log codes can be given in hex
+					number, err := strconv.ParseInt(item, 0, 64)
+					if err != nil {
+						return fmt.Errorf("%s must be a list of numbers: %w", name, err)
+					}
+					numbers = reflect.Append(numbers, reflect.ValueOf(number).Convert(fieldValue.Type().Elem()))
+				}
+				fieldValue.Set(numbers)
+			default:
+				return fmt.Errorf("%s can't be set from the environment", name)
+			}
+		default:
+			return fmt.Errorf("%s can't be set from the environment", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// frameMaxSize caps how large a decompressed frame may be.
+const frameMaxSize = 1 << 20
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// frameData returns the JSON carried by a websocket frame. Text frames are
+// used as is, binary frames are decompressed when they start with a gzip or
+// zlib header and are otherwise tried as raw deflate, unless they're already
+// JSON.
+func frameData(messageType int, data []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return data, nil
+	}
+	var reader io.ReadCloser
+	var err error
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case isZlibHeader(data):
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, frameMaxSize))
+}
+
+// isZlibHeader returns true if the data starts with a zlib header, a deflate
+// compression method byte followed by a byte making the pair a multiple of 31.
+func isZlibHeader(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
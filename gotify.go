@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Gotify doesn't limit messages itself, these keep one from a runaway log line
+// readable in the app.
+const gotifyTitleLimit = 250
+const gotifyMessageLimit = 4096
+
+// gotifyPriorities maps Pushover priorities, -2 to 2, on to Gotify's 0 to 10.
+var gotifyPriorities = map[int]int{-2: 0, -1: 2, 0: 5, 1: 8, 2: 10}
+
+type GotifyNotifier struct {
+	Server   string
+	AppToken string
+}
+
+func (n *GotifyNotifier) Name() string {
+	return "gotify"
+}
+
+func (n *GotifyNotifier) Send(ctx context.Context, notification *Notification) error {
+	messageUrl := strings.TrimRight(n.Server, "/") + "/message?" + url.Values{"token": {n.AppToken}}.Encode()
+	resp, err := postJSON(ctx, httpClient, messageUrl, map[string]interface{}{
+		"title":    truncateString(notification.Title, gotifyTitleLimit),
+		"message":  truncateString(notification.Message, gotifyMessageLimit),
+		"priority": gotifyPriorities[notification.Priority],
+	})
+	if err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+	return nil
+}
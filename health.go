@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// inputConnections counts the websocket servers connected to, or is 1 while
+// following the log file.
+var inputConnections atomic.Int32
+
+// lastMessageAt is the unix nano time the last message was received.
+var lastMessageAt atomic.Int64
+
+type HealthStatus struct {
+	Connected   bool       `json:"connected"`
+	LastMessage *time.Time `json:"last_message,omitempty"`
+}
+
+func markMessageReceived() {
+	lastMessageAt.Store(time.Now().UnixNano())
+}
+
+// handleHealth responds with 200 when connected and 503 when not.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{Connected: inputConnections.Load() > 0}
+	if lastMessage := lastMessageAt.Load(); lastMessage != 0 {
+		lastMessageTime := time.Unix(0, lastMessage)
+		status.LastMessage = &lastMessageTime
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Connected {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// serveHealth serves the /healthz endpoint on the given port.
+func serveHealth(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealth)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		slog.Error("Unable to serve health endpoint", "port", port, "error", err)
+	}
+}
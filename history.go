@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is a line of the notification log.
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// historyLock serializes writes to the notification log.
+var historyLock sync.Mutex
+
+// recordNotification appends the notification and the result of sending it
+// to the notification log, if one is configured.
+func recordNotification(notification *Notification, sendErr error) {
+	configLock.RLock()
+	path := config.NotificationLog
+	syncWrites := config.NotificationLogSync
+	configLock.RUnlock()
+	if path == "" {
+		return
+	}
+	entry := HistoryEntry{
+		Time:      time.Now(),
+		Type:      notification.Type,
+		Title:     notification.Title,
+		Message:   notification.Message,
+		Delivered: sendErr == nil,
+	}
+	if sendErr != nil {
+		entry.Error = sendErr.Error()
+	}
+	if err := appendHistory(path, entry, syncWrites); err != nil {
+		slog.Warn("Unable to write notification log", "path", path, "error", err)
+	}
+}
+
+func appendHistory(path string, entry HistoryEntry, syncWrites bool) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if syncWrites {
+		return f.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// defaultConfig is the documented example config.
+//
+//go:embed config.yml.dist
+var defaultConfig []byte
+
+// writeDefaultConfig writes the example config to the path, an existing file
+// is only replaced when force is set.
+func writeDefaultConfig(path string, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(defaultConfig); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"regexp"
+)
+
+const defaultClientLanguage = "en"
+
+// ClientStrings are the system messages matched for a game client language.
+// The patterns capture the player's name in their first group. DutyReady has
+// to mention the duty as other system messages say something "is ready".
+type ClientStrings struct {
+	PartyFilled    string
+	PartyDisbanded string
+	DutyReady      *regexp.Regexp
+	PartyKicked    string
+	PartyJoin      *regexp.Regexp
+	PartyLeave     *regexp.Regexp
+	PartyInvite    *regexp.Regexp
+	PartyLeader    *regexp.Regexp
+	TradeRequest   *regexp.Regexp
+	// VentureDone captures the retainer's name, which may be empty.
+	VentureDone *regexp.Regexp
+	FCLogin     *regexp.Regexp
+	FCLogout    *regexp.Regexp
+	PartyDeath  *regexp.Regexp
+	// ReadyCheck captures who started the ready check, ReadyCheckDone is
+	// shown once everyone has answered.
+	ReadyCheck     *regexp.Regexp
+	ReadyCheckDone string
+	// DutyBegun and DutyEnded capture the name of the duty.
+	DutyBegun *regexp.Regexp
+	DutyEnded *regexp.Regexp
+	// PartyLeftSelf starts the line shown when you leave the party yourself,
+	// which PartyLeave would otherwise match with "You have" as the name.
+	PartyLeftSelf string
+}
+
+// clientStrings are keyed by the client_language config value. The log codes
+// are the same for every client, only the message text differs.
+var clientStrings = map[string]ClientStrings{
+	"en": {
+		PartyFilled:    "have been filled",
+		PartyDisbanded: "has been disbanded",
+		DutyReady:      regexp.MustCompile(`^(?:Duty Finder: )?(?:The )?[Dd]uty\b.*\bis ready`),
+		PartyKicked:    "You have been removed from the party",
+		PartyJoin:      regexp.MustCompile(`^(.+?) joins the party`),
+		PartyLeave:     regexp.MustCompile(`^(.+?) (?:has )?left the party`),
+		PartyInvite:    regexp.MustCompile(`^(.+?) invites you to (?:join )?a party`),
+		PartyLeader:    regexp.MustCompile(`[Ll]eadership (?:has been )?(?:transferred|passed) to (.+?)\.?$`),
+		TradeRequest:   regexp.MustCompile(`^(.+?) wishes to trade with you`),
+		VentureDone:    regexp.MustCompile(`(?i)^(?:(?:Your retainer )?(\S+) has )?(?:completed|returned from) (?:a|its|her|his|their) venture`),
+		FCLogin:        regexp.MustCompile(`^(.+?) has logged in`),
+		FCLogout:       regexp.MustCompile(`^(.+?) has logged out`),
+		PartyDeath:     regexp.MustCompile(`^(.+?) (?:was|is) defeated by`),
+		ReadyCheck:     regexp.MustCompile(`^(.+?) has initiated a ready check`),
+		ReadyCheckDone: "Ready check complete",
+		DutyBegun:      regexp.MustCompile(`^(.+?) has begun\.`),
+		DutyEnded:      regexp.MustCompile(`^(.+?) has ended\.`),
+		PartyLeftSelf:  "You have left the party",
+	},
+	"fr": {
+		PartyFilled:    "ont été trouvés",
+		PartyDisbanded: "L'équipe a été dissoute",
+		DutyReady:      regexp.MustCompile(`^(?:Outil de mission : )?(?:La )?[Mm]ission\b.*\best prête?`),
+		PartyKicked:    "Vous avez été exclu de l'équipe",
+		PartyJoin:      regexp.MustCompile(`^(.+?) rejoint l'équipe`),
+		PartyLeave:     regexp.MustCompile(`^(.+?) a quitté l'équipe`),
+		PartyInvite:    regexp.MustCompile(`^(.+?) vous invite à rejoindre son équipe`),
+		PartyLeader:    regexp.MustCompile(`direction de l'équipe a été confiée à (.+?)\.?$`),
+		TradeRequest:   regexp.MustCompile(`^(.+?) souhaite procéder à un échange avec vous`),
+		VentureDone:    regexp.MustCompile(`^(?:Votre servant )?(\S*) ?(?:a terminé|est revenu(?:e)? de) (?:sa|une) tâche`),
+		FCLogin:        regexp.MustCompile(`^(.+?) s'est connecté`),
+		FCLogout:       regexp.MustCompile(`^(.+?) s'est déconnecté`),
+		PartyDeath:     regexp.MustCompile(`^(.+?) (?:a été|est) vaincue? par`),
+		ReadyCheck:     regexp.MustCompile(`^(.+?) (?:a lancé|lance) un appel de préparation`),
+		ReadyCheckDone: "L'appel de préparation est terminé",
+		DutyBegun:      regexp.MustCompile(`^(?:La mission )?« ?(.+?) ?» (?:commence|a commencé)`),
+		DutyEnded:      regexp.MustCompile(`^(?:La mission )?« ?(.+?) ?» (?:est terminée?|a pris fin)`),
+		PartyLeftSelf:  "Vous avez quitté l'équipe",
+	},
+	"de": {
+		PartyFilled:    "Gruppe ist vollständig",
+		PartyDisbanded: "Gruppe wurde aufgelöst",
+		DutyReady:      regexp.MustCompile(`^(?:Inhaltssuche: )?(?:Der |Die )?(?:Inhalt|Mission)\b.*\bist bereit`),
+		PartyKicked:    "Du wurdest aus der Gruppe entfernt",
+		PartyJoin:      regexp.MustCompile(`^(.+?) ist der Gruppe beigetreten`),
+		PartyLeave:     regexp.MustCompile(`^(.+?) hat die Gruppe verlassen`),
+		PartyInvite:    regexp.MustCompile(`^(.+?) lädt dich in (?:seine|ihre) Gruppe ein`),
+		PartyLeader:    regexp.MustCompile(`^(.+?) ist (?:jetzt|nun) Gruppenanführer`),
+		TradeRequest:   regexp.MustCompile(`^(.+?) möchte mit dir handeln`),
+		VentureDone:    regexp.MustCompile(`^(?:Dein Gehilfe )?(\S*) ?hat (?:seine|ihre|eine) Unternehmung abgeschlossen`),
+		FCLogin:        regexp.MustCompile(`^(.+?) hat sich eingeloggt`),
+		FCLogout:       regexp.MustCompile(`^(.+?) hat sich ausgeloggt`),
+		PartyDeath:     regexp.MustCompile(`^(.+?) wurde von .+ besiegt`),
+		ReadyCheck:     regexp.MustCompile(`^(.+?) (?:hat|haben) einen Bereitschaftscheck (?:gestartet|eingeleitet)`),
+		ReadyCheckDone: "Bereitschaftscheck abgeschlossen",
+		DutyBegun:      regexp.MustCompile(`^„?(.+?)“? hat begonnen`),
+		DutyEnded:      regexp.MustCompile(`^„?(.+?)“? (?:wurde beendet|ist beendet)`),
+		PartyLeftSelf:  "Du hast die Gruppe verlassen",
+	},
+	"ja": {
+		PartyFilled:    "募集人数に達しました",
+		PartyDisbanded: "パーティが解散されました",
+		DutyReady:      regexp.MustCompile(`突入準備が完了しました`),
+		PartyKicked:    "パーティから除名されました",
+		PartyJoin:      regexp.MustCompile(`^(.+?)がパーティに参加しました`),
+		PartyLeave:     regexp.MustCompile(`^(.+?)がパーティから離脱しました`),
+		PartyInvite:    regexp.MustCompile(`^(.+?)からパーティに誘われました`),
+		PartyLeader:    regexp.MustCompile(`^(.+?)がパーティリーダーになりました`),
+		TradeRequest:   regexp.MustCompile(`^(.+?)から取引の申請がありました`),
+		VentureDone:    regexp.MustCompile(`^(?:リテイナー)?「?(.*?)」?(?:が|は)?リテイナーベンチャーを完了しました`),
+		FCLogin:        regexp.MustCompile(`^(.+?)がログインしました`),
+		FCLogout:       regexp.MustCompile(`^(.+?)がログアウトしました`),
+		PartyDeath:     regexp.MustCompile(`^(.+?)は、.+に倒された`),
+		ReadyCheck:     regexp.MustCompile(`^(.+?)がレディチェックを開始しました`),
+		ReadyCheckDone: "レディチェックが完了しました",
+		DutyBegun:      regexp.MustCompile(`^「(.+?)」の攻略を開始した`),
+		DutyEnded:      regexp.MustCompile(`^「(.+?)」の攻略を終了した`),
+		PartyLeftSelf:  "パーティを離脱しました",
+	},
+}
+
+// matchPlayer returns the player captured by the pattern from the line.
+func matchPlayer(pattern *regexp.Regexp, line string) (Player, bool) {
+	match := pattern.FindStringSubmatch(line)
+	if match == nil {
+		return Player{}, false
+	}
+	return parsePlayer(match[1]), true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const logFilePollInterval = 500 * time.Millisecond
+
+// resolveLogFile returns the log file to follow. When the path is a directory
+// this is the most recently modified .log file in it, as ACT starts a new
+// network log file every day.
+func resolveLogFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(path, "*.log"))
+	if err != nil {
+		return "", err
+	}
+	newest := ""
+	newestTime := time.Time{}
+	for _, match := range matches {
+		matchInfo, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if matchInfo.ModTime().After(newestTime) {
+			newest, newestTime = match, matchInfo.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", errors.New("no .log files found in " + path)
+	}
+	return newest, nil
+}
+
+// tailLogFile follows the ACT log file, handling every line written to it
+// until the context is cancelled. Only lines written after it starts are
+// handled, unless the log file changes in which case the new file is read from
+// the start.
+func tailLogFile(ctx context.Context, path string) error {
+	current, err := resolveLogFile(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(current)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	slog.Info("Following log file", "path", current)
+	inputConnections.Add(1)
+	defer inputConnections.Add(-1)
+
+	reader := bufio.NewReader(file)
+	partial := ""
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err == nil {
+			markMessageReceived()
+			if rawLine := strings.TrimRight(partial+line, "\r\n"); watchingCode(rawLine) {
+				handleLogLine(ctx, readLogLing(rawLine))
+			}
+			partial = ""
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		partial += line
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logFilePollInterval):
+		}
+
+		// switch to a newer log file, or start over if this one was truncated
+		next, err := resolveLogFile(path)
+		if err != nil {
+			slog.Warn("Unable to find log file", "error", err)
+			continue
+		}
+		info, err := file.Stat()
+		if next == current && (err != nil || info.Size() >= offset) {
+			continue
+		}
+		nextFile, err := os.Open(next)
+		if err != nil {
+			slog.Warn("Unable to open log file", "error", err)
+			continue
+		}
+		file.Close()
+		file, current, offset, partial = nextFile, next, 0, ""
+		reader.Reset(file)
+		slog.Info("Following log file", "path", current)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+const LogFormatText = "text"
+const LogFormatJSON = "json"
+
+// logLevel is shared by every handler so the level can change on reload.
+var logLevel = &slog.LevelVar{}
+var logFormat = ""
+
+func parseLogLevel(input string) (slog.Level, error) {
+	level := slog.LevelInfo
+	return level, level.UnmarshalText([]byte(input))
+}
+
+// configureLogging applies the configured level and format to the default
+// logger. The handler is only replaced when the format changes.
+func configureLogging(c Config) {
+	level, _ := parseLogLevel(c.LogLevel)
+	logLevel.Set(level)
+	if c.LogFormat == logFormat {
+		return
+	}
+	logFormat = c.LogFormat
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if c.LogFormat == LogFormatJSON {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
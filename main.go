@@ -1,42 +1,59 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"math/big"
-	"net/http"
-	"net/url"
+	"log/slog"
+	"math/rand"
 	"os"
 	"os/signal"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"gopkg.in/yaml.v2"
 )
 
-const messageUrl = "https://api.pushover.net/1/messages.json"
-const configPath = "config.yml"
+const logLineMinFields = 5
+const unmatchedLineLimit = 100
+const reconnectBaseDelay = time.Second
+const reconnectMaxDelay = 30 * time.Second
 
-var spaceCapitalRegex = regexp.MustCompile("([a-z'])([A-Z])")
-var config = Config{}
+// connectionStableAfter is how long a connection has to stay up for the
+// reconnect delay to go back to reconnectBaseDelay.
+const connectionStableAfter = time.Minute
+const pingInterval = 30 * time.Second
+const pongWait = pingInterval + 15*time.Second
+const writeWait = 10 * time.Second
 
-type Config struct {
-	WebsocketPort    int    `yaml:"websocket_port"`
-	PushoverAppToken string `yaml:"pushover_app_token"`
-	PushoverUserKey  string `yaml:"pushover_user_key"`
-	NotifyOnFill     bool   `yaml:"notifiy_on_fill"`
-	NotifyOnDisband  bool   `yaml:"notifiy_on_disband"`
-	NotifyOnJoin     bool   `yaml:"notify_on_join"`
-	NotifyOnLeave    bool   `yaml:"notify_on_leave"`
+// Message is either a MiniParse message, which has the log line as Data when
+// Type is Chat, or an OverlayPlugin event, which has the log line as RawLine,
+// or split in to Fields, when Event is LogLine.
+type Message struct {
+	Type    string      `json:"msgtype"`
+	Data    interface{} `json:"msg"`
+	Event   string      `json:"type"`
+	Fields  []string    `json:"line"`
+	RawLine string      `json:"rawLine"`
 }
 
-type Message struct {
-	Type string      `json:"msgtype"`
-	Data interface{} `json:"msg"`
+// logLineData returns the log line carried by the message, or nil if it
+// isn't a log line.
+func (m Message) logLineData() interface{} {
+	switch {
+	case m.Type == "Chat":
+		return m.Data
+	case m.Event == "LogLine" && m.RawLine != "":
+		return m.RawLine
+	case m.Event == "LogLine":
+		return strings.Join(m.Fields, "|")
+	}
+	return nil
 }
 
 type LogLine struct {
@@ -44,24 +61,8 @@ type LogLine struct {
 	Code int64
 	Name string
 	Line string
-}
-
-type Notification struct {
-	Title   string
-	Message string
-	Sound   string
-}
-
-func loadConfig() error {
-	rawConfig, err := os.ReadFile(configPath)
-	if err != nil {
-		return err
-	}
-	return yaml.Unmarshal(rawConfig, &config)
-}
-
-func addSpaceAfterCapitals(input string) string {
-	return spaceCapitalRegex.ReplaceAllString(input, "$1 $2")
+	// Source is the name of the websocket source the line came from.
+	Source string
 }
 
 func decodeMessage(message []byte) (Message, error) {
@@ -69,157 +70,444 @@ func decodeMessage(message []byte) (Message, error) {
 	return out, json.Unmarshal(message, &out)
 }
 
+// readLogLing parses a chat log line, anything that isn't a well formed chat
+// line results in an empty LogLine.
 func readLogLing(data interface{}) LogLine {
-	splitString := strings.Split(data.(string), "|")
+	rawLine, ok := data.(string)
+	if !ok {
+		slog.Debug("Skipping log line that isn't a string", "type", fmt.Sprintf("%T", data))
+		return LogLine{}
+	}
+	splitString := strings.Split(rawLine, "|")
 	if splitString[0] != "00" {
 		return LogLine{}
 	}
+	if len(splitString) < logLineMinFields {
+		slog.Debug("Skipping log line with too few fields", "fields", len(splitString), "expected", logLineMinFields, "line", rawLine)
+		return LogLine{}
+	}
 
 	timestamp, err := time.Parse(time.RFC3339Nano, splitString[1])
 	if err != nil {
-		log.Println("Unable to parse log timestamp: ", err)
+		slog.Debug("Unable to parse log timestamp", "error", err)
 		return LogLine{}
 	}
 
-	code := new(big.Int)
-	code.SetString(splitString[2], 16)
+	// codes are 4 hex digits so fit in an int64 with plenty of room
+	code, err := strconv.ParseInt(splitString[2], 16, 64)
+	if err != nil {
+		slog.Debug("Unable to parse log code", "code", splitString[2], "error", err)
+		return LogLine{}
+	}
 	return LogLine{
 		Time: timestamp,
-		Code: code.Int64(),
+		Code: code,
 		Name: splitString[3],
 		Line: splitString[4],
 	}
 }
 
-func buildNotification(logLine LogLine) *Notification {
-	switch logLine.Code {
-	case 57: // party filled/disbanded
-		{
-			if config.NotifyOnFill && strings.Contains(logLine.Line, "have been filled") {
-				return &Notification{
-					Title:   "Your Party Has Filled",
-					Message: logLine.Line,
-					Sound:   "gamelan",
-				}
-			} else if config.NotifyOnDisband && strings.Contains(logLine.Line, "has been disbanded") {
-				return &Notification{
-					Title:   "Your Party Has Disbanded",
-					Message: logLine.Line,
-					Sound:   "none",
-				}
-			}
-		}
-	case 8761: // join/leave/return to party
-		{
-			if config.NotifyOnJoin && strings.Contains(logLine.Line, "joins the party") {
-				return &Notification{
-					Title:   "Player Joined Your Party",
-					Message: addSpaceAfterCapitals(logLine.Line),
-					Sound:   "none",
-				}
-			} else if config.NotifyOnLeave && strings.Contains(logLine.Line, "left the party") {
-				return &Notification{
-					Title:   "Player Left Your Party",
-					Message: addSpaceAfterCapitals(logLine.Line),
-					Sound:   "none",
-				}
-			}
-			break
-		}
+// watchingCode returns true if watch_codes is empty or includes the code of
+// the raw log line. Only the code is parsed so that lines that aren't watched
+// are skipped cheaply.
+func watchingCode(rawLine string) bool {
+	configLock.RLock()
+	watchCodes := config.WatchCodes
+	configLock.RUnlock()
+	if len(watchCodes) == 0 {
+		return true
 	}
+	rest, ok := strings.CutPrefix(rawLine, "00|")
+	if !ok {
+		return false
+	}
+	_, rest, _ = strings.Cut(rest, "|")
+	field, _, _ := strings.Cut(rest, "|")
+	code, err := strconv.ParseInt(field, 16, 64)
+	return err == nil && slices.Contains(watchCodes, code)
+}
 
-	return nil
+// backoffDelay returns the given delay with up to half of it randomized away
+// so that multiple clients don't retry in lockstep.
+func backoffDelay(delay time.Duration) time.Duration {
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
 }
 
-func sendNotification(notification *Notification) {
-	data := map[string]string{
-		"token":   config.PushoverAppToken,
-		"user":    config.PushoverUserKey,
-		"title":   notification.Title,
-		"message": notification.Message,
-		"sound":   notification.Sound,
+// logUnmatched logs, at debug level, a parsed log line that didn't produce a
+// notification to help identify the codes and text of new events.
+func logUnmatched(ctx context.Context, logLine LogLine) {
+	if logLine.Code == 0 || !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
 	}
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Println("Unable to encode notification: ", err)
+	slog.Debug("Log line produced no notification", "code", fmt.Sprintf("%04X", logLine.Code), "channel", channelName(logLine.Code), "line", truncateString(logLine.Line, unmatchedLineLimit))
+}
+
+// handleLogLine builds the notification for the log line and, unless it is
+// suppressed, sends it.
+func handleLogLine(ctx context.Context, logLine LogLine) {
+	configLock.RLock()
+	updateDutyState(config, logLine)
+	notification := buildNotification(config, logLine)
+	suppressed := notification != nil && (suppressedInDuty(config, notification, logLine.Source) || shouldSuppress(notification, time.Now()))
+	if notification != nil && !suppressed {
+		silenceRepeatSound(notification)
+	}
+	currentNotifiers := notifiers
+	digestWindow := time.Duration(config.DigestSeconds) * time.Second
+	digestMaxEvents := config.DigestMaxEvents
+	rejoinWindow := time.Duration(config.RejoinWindowSeconds) * time.Second
+	configLock.RUnlock()
+	if notification == nil {
+		logUnmatched(ctx, logLine)
 		return
 	}
-	if _, err := http.Post(messageUrl, "application/json", bytes.NewReader(jsonData)); err != nil {
-		log.Println("Unable to send notification: ", err)
+	if logLine.Source != "" {
+		notification.Title = fmt.Sprintf("[%s] %s", logLine.Source, notification.Title)
+	}
+	if suppressed {
 		return
 	}
-	log.Printf("Sent notification: %s", notification.Title)
-}
-
-func main() {
-
-	if err := loadConfig(); err != nil {
-		log.Fatal("Unable to read config: ", err)
+	send := func() {
+		if digestWindow > 0 {
+			digest.Add(notification, digestWindow, digestMaxEvents)
+			return
+		}
+		queueNotification(currentNotifiers, notification)
 	}
-
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
-	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("127.0.0.1:%d", config.WebsocketPort), Path: "MiniParse"}
-
-	var c *websocket.Conn = nil
-	var err error
-
-	// wait 5 seconds before trying to connect
-	time.Sleep(5 * time.Second)
-
-	c, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
-	if err != nil {
-		log.Fatalf("Failed to connect to websocket server at %s.", u.String())
+	if rejoinWindow > 0 && isPartyChange(notification) {
+		rejoins.Hold(notification, rejoinWindow, send)
+		return
 	}
-	defer c.Close()
-	log.Printf("Connected to websocket server at %s.", u.String())
-
-	done := make(chan struct{})
+	send()
+}
 
-	go func() {
-		defer close(done)
-		for {
-			_, rawMessage, err := c.ReadMessage()
-			if err != nil {
-				log.Println("Unable to fetch message:", err)
-				return
+// readMessages reads and handles messages from the websocket connection until
+// it errors, returning the error. received is signalled whenever a message is
+// read and firstLogLine is closed once a log line is.
+//
+// lastSeen is the time of the newest line handled from the source, it is kept
+// across reconnects so that lines the server replays on reconnecting, which
+// are at or before it, are skipped instead of notified about again.
+func readMessages(ctx context.Context, c *websocket.Conn, source WebsocketSource, lastSeen *time.Time, received chan<- struct{}, firstLogLine chan struct{}) error {
+	highWaterMark := *lastSeen
+	c.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		messageType, rawMessage, err := c.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+			// e.g. ACT was closed, it'll be reconnected to once it's back
+			slog.Info("Websocket server closed the connection", "code", err.(*websocket.CloseError).Code)
+			return err
+		}
+		if err != nil {
+			slog.Debug("Unable to fetch message", "error", err)
+			return err
+		}
+		c.SetReadDeadline(time.Now().Add(pongWait))
+		markMessageReceived()
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		payload, err := frameData(messageType, rawMessage)
+		if err != nil {
+			slog.Warn("Unable to decompress message, skipping it", "error", err)
+			continue
+		}
+		message, err := decodeMessage(payload)
+		if err != nil {
+			slog.Warn("Unable to decode message, skipping it", "error", err)
+			continue
+		}
+		if data := message.logLineData(); data != nil {
+			select {
+			case <-firstLogLine:
+			default:
+				close(firstLogLine)
 			}
-			message, err := decodeMessage(rawMessage)
-			if err != nil {
-				log.Println("Unable to decode message: ", err)
-				return
+			if rawLine, ok := data.(string); ok && !watchingCode(rawLine) {
+				continue
+			}
+			logLine := readLogLing(data)
+			if !logLine.Time.IsZero() && !logLine.Time.After(highWaterMark) {
+				slog.Debug("Skipping log line already handled before reconnecting", "time", logLine.Time)
+				continue
 			}
-			if message.Type == "Chat" {
-				logLing := readLogLing(message.Data)
-				notification := buildNotification(logLing)
-				if notification != nil {
-					sendNotification(notification)
-				}
+			if logLine.Time.After(*lastSeen) {
+				*lastSeen = logLine.Time
 			}
+			logLine.Source = source.Name
+			handleLogLine(ctx, logLine)
 		}
+	}
+}
+
+// handleConnection reads from the connection until it drops or the context
+// is cancelled. It returns true if the caller should reconnect.
+//
+// The server is pinged periodically, a connection that stops answering is
+// half-open and the read deadline expiring drops it. With stale_timeout_seconds
+// set the connection is also dropped when no message arrives for that long,
+// in case the server stops sending while still answering pings.
+//
+// When no log line arrives within initial_message_timeout_seconds of
+// connecting a warning is logged, as the server is most likely never going to
+// send any because of the wrong path or a missing subscription.
+//
+// A server closing the connection with a code not in reconnect_close_codes,
+// such as a policy violation, is rejecting us for a reason reconnecting won't
+// fix so this exits instead.
+func handleConnection(ctx context.Context, c *websocket.Conn, source WebsocketSource, lastSeen *time.Time) bool {
+	configLock.RLock()
+	staleTimeout := time.Duration(config.StaleTimeoutSeconds) * time.Second
+	initialTimeout := time.Duration(*config.InitialTimeoutSeconds) * time.Second
+	reconnectCodes := config.ReconnectCloseCodes
+	configLock.RUnlock()
+
+	done := make(chan struct{})
+	received := make(chan struct{}, 1)
+	firstLogLine := make(chan struct{})
+	var readErr error
+	go func() {
+		readErr = readMessages(ctx, c, source, lastSeen, received, firstLogLine)
+		close(done)
 	}()
+	defer func() {
+		// wake a read that is still blocked and wait for it to return
+		c.SetReadDeadline(time.Now())
+		<-done
+		c.Close()
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	// a nil channel never fires so the watchdog is disabled without a timeout
+	var stale <-chan time.Time
+	var staleTimer *time.Timer
+	if staleTimeout > 0 {
+		staleTimer = time.NewTimer(staleTimeout)
+		defer staleTimer.Stop()
+		stale = staleTimer.C
+	}
+	var initial <-chan time.Time
+	if initialTimeout > 0 {
+		initialTimer := time.NewTimer(initialTimeout)
+		defer initialTimer.Stop()
+		initial = initialTimer.C
+	}
 
 	for {
 		select {
 		case <-done:
-			return
-		case <-interrupt:
-			log.Println("Interupt detected. Closing connection.")
+			// only reconnect if the connection wasn't closed by an interrupt
+			if ctx.Err() != nil {
+				return false
+			}
+			closeErr := &websocket.CloseError{}
+			if errors.As(readErr, &closeErr) && !slices.Contains(reconnectCodes, closeErr.Code) {
+				u := source.URL()
+				fatal("Websocket server closed the connection with a code that isn't reconnected on, see reconnect_close_codes", "url", u.String(), "code", closeErr.Code, "reason", closeErr.Text)
+			}
+			return true
+		case <-received:
+			if staleTimer != nil {
+				staleTimer.Reset(staleTimeout)
+			}
+		case <-firstLogLine:
+			initial, firstLogLine = nil, nil
+		case <-initial:
+			u := source.URL()
+			slog.Warn("Connected but no log lines have been received from the websocket server, check websocket_path and subscribe_events are right for your plugin", "url", u.String(), "initial_message_timeout", initialTimeout, "subscribe_events", source.SubscribeEvents)
+			initial = nil
+		case <-stale:
+			u := source.URL()
+			slog.Warn("No message received from the websocket server, reconnecting", "url", u.String(), "stale_timeout", staleTimeout)
+			return true
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				slog.Debug("Unable to write ping message", "error", err)
+				return true
+			}
+		case <-ctx.Done():
+			slog.Info("Interupt detected. Closing connection.")
 
 			// Cleanly close the connection by sending a close message and then
 			// waiting (with timeout) for the server to close the connection.
-			err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			err := c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
 			if err != nil {
-				log.Println("write close:", err)
-				return
+				slog.Debug("Unable to write close message", "error", err)
+				return false
 			}
 			select {
 			case <-done:
 			case <-time.After(time.Second):
 			}
-			return
+			return false
+		}
+	}
+}
+
+func main() {
+
+	flag.StringVar(&configPath, "config", defaultConfigPath, "path to the config file")
+	sendTest := flag.Bool("test", false, "send a test notification and exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	replayPath := flag.String("replay", "", "print the notifications a captured log file would send and exit")
+	flag.BoolVar(&dryRun, "dry-run", false, "log notifications instead of sending them")
+	initConfig := flag.Bool("init", false, "write a default config file to the config path and exit")
+	forceInit := flag.Bool("force", false, "with -init, overwrite an existing config file")
+	validate := flag.Bool("validate", false, "check the config file, printing any problems, and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	if *initConfig {
+		if err := writeDefaultConfig(configPath, *forceInit); err != nil {
+			fatal("Unable to write config", "error", err)
+		}
+		fmt.Printf("Wrote %s, set your Pushover app token and user key in it to get started.\n", configPath)
+		return
+	}
+
+	if *validate {
+		if !checkConfigFile() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// replaying doesn't send anything so the config only needs to be readable
+	if *replayPath != "" {
+		var err error
+		if config, err = readConfig(); err != nil {
+			fatal("Unable to read config", "error", err)
+		}
+		configureLogging(config)
+		if err := replayLogFile(*replayPath); err != nil {
+			fatal("Unable to replay log file", "error", err)
+		}
+		return
+	}
+
+	if err := loadConfig(); err != nil {
+		fatal("Unable to load config", "error", err)
+	}
+
+	if *sendTest {
+		notification := &Notification{
+			Title:   "Test",
+			Message: "This is a test notification from xiv_party_notification.",
+			Sound:   "gamelan",
+		}
+		if err := sendNotification(context.Background(), notifiers, notification); err != nil {
+			fatal("Test notification failed", "error", err)
+		}
+		slog.Info("Test notification sent.")
+		return
+	}
+	go watchConfig()
+	go reloadOnHangup()
+	if config.HealthPort != 0 {
+		go serveHealth(config.HealthPort)
+	}
+	if config.MetricsEnabled {
+		go serveMetrics(config.MetricsPort)
+	}
+
+	defer closeMqtt()
+
+	// cancelled on interrupt to close the connection, notifications already
+	// queued are still sent for up to shutdown_timeout_seconds
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	startNotificationWorkers()
+	defer func() {
+		configLock.RLock()
+		timeout := time.Duration(*config.ShutdownTimeoutSeconds) * time.Second
+		configLock.RUnlock()
+		flushNotifications(timeout)
+	}()
+
+	if config.InputMode == InputModeLogFile {
+		if err := tailLogFile(ctx, config.LogFilePath); err != nil {
+			fatal("Unable to read log file", "error", err)
 		}
+		return
 	}
 
+	// every source feeds the same notifications
+	wg := sync.WaitGroup{}
+	for _, source := range websocketSources(config) {
+		wg.Add(1)
+		go func(source WebsocketSource) {
+			defer wg.Done()
+			runWebsocket(ctx, source)
+		}(source)
+	}
+	wg.Wait()
+}
+
+// runWebsocket connects to the websocket server and handles its messages,
+// reconnecting whenever the connection drops, until the context is cancelled.
+func runWebsocket(ctx context.Context, source WebsocketSource) {
+	u := source.URL()
+
+	// wait 5 seconds before trying to connect
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(5 * time.Second):
+	}
+
+	dialer := source.Dialer()
+	delay := reconnectBaseDelay
+	lastSeen := time.Time{}
+	for attempt := 1; ; attempt++ {
+		slog.Debug("Connecting to websocket server", "url", u.String(), "attempt", attempt)
+		c, _, err := dialer.DialContext(ctx, u.String(), nil)
+		if err != nil {
+			wait := backoffDelay(delay)
+			slog.Warn("Failed to connect to websocket server", "url", u.String(), "error", err, "retry_in", wait.Round(time.Millisecond))
+			select {
+			case <-ctx.Done():
+				slog.Info("Interupt detected. Giving up on connecting.")
+				return
+			case <-time.After(wait):
+			}
+			delay = min(delay*2, reconnectMaxDelay)
+			continue
+		}
+		slog.Debug("Connected to websocket server", "url", u.String())
+		if err := source.subscribe(c); err != nil {
+			slog.Warn("Unable to subscribe to events", "url", u.String(), "events", source.SubscribeEvents, "error", err)
+		}
+		inputConnections.Add(1)
+		connectedAt, seenBefore := time.Now(), lastSeen
+
+		reconnect := handleConnection(ctx, c, source, &lastSeen)
+		inputConnections.Add(-1)
+		if !reconnect {
+			return
+		}
+		// a server that accepts and then closes the connection straight away
+		// backs off the same as one refusing it
+		if time.Since(connectedAt) >= connectionStableAfter || lastSeen.After(seenBefore) {
+			delay = reconnectBaseDelay
+			attempt = 0
+		}
+		wait := backoffDelay(delay)
+		slog.Debug("Lost connection to websocket server, reconnecting", "url", u.String(), "retry_in", wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
 }
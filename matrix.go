@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixTxnCounter makes transaction ids unique within the process, the
+// start time makes them unique across restarts.
+var matrixTxnCounter atomic.Int64
+
+var matrixTxnPrefix = fmt.Sprintf("xpn-%d", time.Now().UnixNano())
+
+// matrixTitleLimit and matrixMessageLimit keep the event, which has the text
+// twice, once HTML escaped, well under the homeserver's 65536 byte limit.
+const matrixTitleLimit = 256
+const matrixMessageLimit = 4096
+
+type MatrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+type MatrixNotifier struct {
+	Homeserver  string
+	AccessToken string
+	RoomID      string
+}
+
+func (n *MatrixNotifier) Name() string {
+	return "matrix"
+}
+
+func (n *MatrixNotifier) Send(ctx context.Context, notification *Notification) error {
+	txnID := fmt.Sprintf("%s-%d", matrixTxnPrefix, matrixTxnCounter.Add(1))
+	sendUrl := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", strings.TrimRight(n.Homeserver, "/"), url.PathEscape(n.RoomID), txnID)
+	title := truncateString(notification.Title, matrixTitleLimit)
+	text := truncateString(notification.Message, matrixMessageLimit)
+	message := MatrixMessage{
+		MsgType:       "m.notice",
+		Body:          title + "\n" + text,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: "<b>" + html.EscapeString(title) + "</b><br>" + html.EscapeString(text),
+	}
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sendUrl, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.AccessToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	return nil
+}
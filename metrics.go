@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultMetricsPort = 9091
+
+var (
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xiv_notifications_sent_total",
+		Help: "Notifications delivered, by event type and backend.",
+	}, []string{"type", "backend"})
+	notificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xiv_notification_failures_total",
+		Help: "Notifications that failed to deliver after retrying, by event type and backend.",
+	}, []string{"type", "backend"})
+	notificationRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xiv_notification_retries_total",
+		Help: "Notification sends that failed and were retried, by backend.",
+	}, []string{"backend"})
+	notificationsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xiv_notifications_dropped_total",
+		Help: "Notifications dropped because the send queue was full.",
+	})
+	notificationLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "xiv_notification_send_seconds",
+		Help:    "Time taken to deliver a notification, including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend"})
+	websocketConnected = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "xiv_websocket_connected",
+		Help: "The number of websocket servers connected to, or 1 while following the log file.",
+	}, func() float64 {
+		return float64(inputConnections.Load())
+	})
+)
+
+// serveMetrics serves the Prometheus /metrics endpoint on the given port.
+func serveMetrics(port int) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(notificationsSent, notificationFailures, notificationRetries, notificationsDropped, notificationLatency, websocketConnected)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		slog.Error("Unable to serve metrics endpoint", "port", port, "error", err)
+	}
+}
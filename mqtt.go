@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttClientID = "xiv_party_notification"
+const mqttQoS = 1
+
+// the connection is shared by every MqttNotifier built from the same settings
+// so that it survives config reloads, it is replaced when they change.
+var (
+	mqttLock     sync.Mutex
+	mqttClient   mqtt.Client
+	mqttSettings MqttNotifier
+)
+
+type MqttNotifier struct {
+	Broker   string
+	Topic    string
+	Username string
+	Password string
+}
+
+type MqttPayload struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Sound    string `json:"sound"`
+	Priority int    `json:"priority"`
+}
+
+func (n *MqttNotifier) Name() string {
+	return "mqtt"
+}
+
+// client returns the shared connection, connecting if needed. The client
+// reconnects by itself if the connection drops.
+func (n *MqttNotifier) client(ctx context.Context) (mqtt.Client, error) {
+	mqttLock.Lock()
+	defer mqttLock.Unlock()
+	if mqttClient != nil && mqttSettings != *n {
+		mqttClient.Disconnect(250)
+		mqttClient = nil
+	}
+	if mqttClient == nil {
+		opts := mqtt.NewClientOptions().
+			AddBroker(n.Broker).
+			SetClientID(mqttClientID).
+			SetUsername(n.Username).
+			SetPassword(n.Password).
+			SetAutoReconnect(true).
+			SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+				slog.Warn("Lost connection to MQTT broker, reconnecting", "broker", n.Broker, "error", err)
+			})
+		mqttClient, mqttSettings = mqtt.NewClient(opts), *n
+	}
+	if mqttClient.IsConnectionOpen() {
+		return mqttClient, nil
+	}
+	if err := waitForToken(ctx, mqttClient.Connect()); err != nil {
+		return nil, err
+	}
+	slog.Debug("Connected to MQTT broker", "broker", n.Broker)
+	return mqttClient, nil
+}
+
+func (n *MqttNotifier) Send(ctx context.Context, notification *Notification) error {
+	client, err := n.client(ctx)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	payload, err := json.Marshal(MqttPayload{
+		Type:     notification.Type,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Sound:    notification.Sound,
+		Priority: notification.Priority,
+	})
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	if err := waitForToken(ctx, client.Publish(n.Topic, mqttQoS, false, payload)); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// waitForToken waits for an MQTT operation to finish, up to the http timeout
+// used by the other notifiers.
+func waitForToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(httpTimeout):
+		return errors.New("timed out waiting for the broker")
+	}
+}
+
+// closeMqtt disconnects from the MQTT broker, if connected.
+func closeMqtt() {
+	mqttLock.Lock()
+	defer mqttLock.Unlock()
+	if mqttClient != nil {
+		mqttClient.Disconnect(250)
+		mqttClient = nil
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// event types, these are used as the keys of the per event config maps
+const (
+	EventFill           = "fill"
+	EventDisband        = "disband"
+	EventJoin           = "join"
+	EventLeave          = "leave"
+	EventDutyPop        = "duty_pop"
+	EventTell           = "tell"
+	EventInvite         = "invite"
+	EventKick           = "kick"
+	EventLeaderChange   = "leader_change"
+	EventTrade          = "trade"
+	EventVenture        = "venture"
+	EventFCLogin        = "fc_login"
+	EventFCLogout       = "fc_logout"
+	EventPartyDeath     = "party_death"
+	EventNovice         = "novice_network"
+	EventReadyCheck     = "ready_check"
+	EventReadyDone      = "ready_check_done"
+	EventFCAnnouncement = "fc_announcement"
+)
+
+// defaultPriorities are used for events without a priority in the config,
+// anything not listed has normal priority.
+var defaultPriorities = map[string]int{
+	EventDutyPop:    1,
+	EventReadyCheck: 1,
+}
+
+// defaultCooldowns are used for events without a cooldown in the config,
+// deaths are rate limited as a wipe would otherwise send one for everyone.
+var defaultCooldowns = map[string]int{
+	EventPartyDeath: 10,
+}
+
+// battleDefeatCode is the low seven bits of the log codes of defeat messages,
+// the bits above say who was defeated by whom. Bits 11 to 14 are the relation
+// to you of the character defeated and bits 7 to 10 that of who defeated them.
+const battleDefeatCode = 0x3A
+
+// relationPartyMember is the relation of a character in your party other than
+// you, enemies and players outside the party have their own.
+const relationPartyMember = 2
+
+// isPartyMemberDefeat returns true for the defeat log codes where the
+// character defeated is in your party.
+func isPartyMemberDefeat(code int64) bool {
+	return code&0x7F == battleDefeatCode && (code>>11)&0xF == relationPartyMember
+}
+
+var spaceCapitalRegex = regexp.MustCompile("([a-z'])([A-Z])")
+
+// Player is a character, World is empty when the log line didn't include it.
+type Player struct {
+	Name  string
+	World string
+}
+
+func (p Player) String() string {
+	if p.World == "" {
+		return p.Name
+	}
+	return fmt.Sprintf("%s — %s", p.Name, p.World)
+}
+
+type Notification struct {
+	Type     string
+	Title    string
+	Message  string
+	Sound    string
+	Priority int
+	// URL is opened when the notification is tapped, URLTitle is shown in
+	// place of it.
+	URL      string
+	URLTitle string
+	// Attachment is the path of an image to attach, if any.
+	Attachment string
+	// Highlight is the part of the message, usually a player name, that
+	// backends supporting formatting emphasise.
+	Highlight string
+	// Source is the name of the websocket source that caused the
+	// notification, if it has one.
+	Source string
+	// Player is who the notification is about, if anyone.
+	Player Player
+}
+
+// newNotification builds a notification for the event type, applying any
+// sound, priority and prefixes configured for it over the given defaults.
+func newNotification(c Config, event string, title string, message string, sound string) *Notification {
+	if configuredSound, ok := c.Sounds[event]; ok {
+		sound = configuredSound
+	}
+	priority := defaultPriorities[event]
+	if configuredPriority, ok := c.Priorities[event]; ok {
+		priority = configuredPriority
+	}
+	return &Notification{
+		Type:       event,
+		Title:      c.TitlePrefixes[event] + title,
+		Message:    c.MessagePrefixes[event] + message,
+		Sound:      sound,
+		Priority:   priority,
+		URL:        c.URLs[event],
+		URLTitle:   c.URLTitles[event],
+		Attachment: c.Attachments[event],
+	}
+}
+
+// highlighted sets the text to emphasise in the notification's message.
+func highlighted(notification *Notification, text string) *Notification {
+	notification.Highlight = text
+	return notification
+}
+
+// forPlayer sets the player the notification is about, highlighting them.
+func forPlayer(notification *Notification, player Player) *Notification {
+	notification.Player = player
+	return highlighted(notification, player.String())
+}
+
+func addSpaceAfterCapitals(input string) string {
+	return spaceCapitalRegex.ReplaceAllString(input, "$1 $2")
+}
+
+// matchesPlayerName returns true if the name, after addSpaceAfterCapitals,
+// is the given player. Cross-world names have the world appended so this is
+// allowed to follow the name.
+func matchesPlayerName(name string, player string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	player = strings.ToLower(strings.TrimSpace(player))
+	return name == player || strings.HasPrefix(name, player+" ")
+}
+
+func matchesAnyPlayerName(name string, players []string) bool {
+	for _, player := range players {
+		if matchesPlayerName(name, player) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePlayer splits a player name from the log in to the character name and
+// world. Cross-world players have their world appended straight after their
+// name, sometimes with an icon glyph or @ in between.
+func parsePlayer(raw string) Player {
+	raw = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Co, r) || r == '@' {
+			return ' '
+		}
+		return r
+	}, raw)
+	words := strings.Fields(addSpaceAfterCapitals(raw))
+	if len(words) <= 2 {
+		return Player{Name: strings.Join(words, " ")}
+	}
+	return Player{Name: strings.Join(words[:2], " "), World: strings.Join(words[2:], " ")}
+}
+
+// shouldNotifyForPlayer checks the player against the notify_players
+// allowlist and the ignore_players blocklist.
+func shouldNotifyForPlayer(c Config, name string) bool {
+	if len(c.NotifyPlayers) > 0 && !matchesAnyPlayerName(name, c.NotifyPlayers) {
+		return false
+	}
+	return !matchesAnyPlayerName(name, c.IgnorePlayers)
+}
+
+// shouldNotifyForPartyMember applies the player lists and home world filter,
+// and skips your own character, for party join/leave, free company login and
+// death events.
+func shouldNotifyForPartyMember(c Config, player Player) bool {
+	if c.SelfCharacterName != "" && matchesPlayerName(player.Name, c.SelfCharacterName) {
+		return false
+	}
+	// players without a world are from the world you're on
+	if c.HomeWorldOnly && player.World != "" && !strings.EqualFold(player.World, c.HomeWorld) {
+		return false
+	}
+	return shouldNotifyForPlayer(c, player.Name)
+}
+
+// buildNotification returns the notification for the log line, or nil if
+// nothing should be sent for it, under the given config. It doesn't touch any
+// global state so it can be called with any config, an unset client language
+// or timezone is English or the local timezone.
+func buildNotification(c Config, logLine LogLine) *Notification {
+	if !channelAllowed(c, logLine.Code) {
+		return nil
+	}
+	logLine.Time = logLine.Time.In(c.timeLocation())
+	notification := buildEventNotification(c, logLine)
+	if notification != nil {
+		// set before the templates are rendered so that they can use it
+		notification.Source = logLine.Source
+		if err := applyEventTemplates(c, notification, logLine.Line); err != nil {
+			slog.Warn("Unable to render template, using the default", "type", notification.Type, "error", err)
+		}
+	}
+	if notification != nil && c.IncludeTimestamp {
+		notification.Message = fmt.Sprintf("[%s] %s", logLine.Time.Format(c.TimestampFormat), notification.Message)
+	}
+	return notification
+}
+
+func buildEventNotification(c Config, logLine LogLine) *Notification {
+	lang := c.lang()
+	switch logLine.Code {
+	case CodeSystem: // party filled/disbanded/invite/kick/leader, duty ready, trade, venture, ready check
+		{
+			if c.NotifyOnFill && strings.Contains(logLine.Line, lang.PartyFilled) {
+				return newNotification(c, EventFill, "Your Party Has Filled", logLine.Line, "gamelan")
+			} else if c.NotifyOnDisband && strings.Contains(logLine.Line, lang.PartyDisbanded) {
+				return newNotification(c, EventDisband, "Your Party Has Disbanded", logLine.Line, "none")
+			} else if c.NotifyOnDutyPop && lang.DutyReady.MatchString(logLine.Line) {
+				return newNotification(c, EventDutyPop, "Your Duty Is Ready", logLine.Line, "siren")
+			} else if inviter, ok := matchPlayer(lang.PartyInvite, logLine.Line); ok && c.NotifyOnInvite {
+				return forPlayer(newNotification(c, EventInvite, fmt.Sprintf("Party Invite from %s", inviter.Name), fmt.Sprintf("%s invited you to a party.", inviter), "spacealarm"), inviter)
+			} else if c.NotifyOnKick && strings.Contains(logLine.Line, lang.PartyKicked) {
+				return newNotification(c, EventKick, "You Were Removed From The Party", logLine.Line, "falling")
+			} else if leader, ok := matchPlayer(lang.PartyLeader, logLine.Line); ok && c.NotifyOnLeaderChange {
+				if c.SelfCharacterName != "" && matchesPlayerName(leader.Name, c.SelfCharacterName) {
+					return newNotification(c, EventLeaderChange, "You Are Now Party Leader", logLine.Line, "none")
+				}
+				return forPlayer(newNotification(c, EventLeaderChange, "Party Leader Changed", fmt.Sprintf("%s is now the party leader.", leader), "none"), leader)
+			} else if trader, ok := matchPlayer(lang.TradeRequest, logLine.Line); ok && c.NotifyOnTrade {
+				return forPlayer(newNotification(c, EventTrade, fmt.Sprintf("Trade Request from %s", trader.Name), fmt.Sprintf("%s wishes to trade with you.", trader), "cashregister"), trader)
+			} else if match := lang.VentureDone.FindStringSubmatch(logLine.Line); match != nil && c.NotifyOnVenture {
+				if match[1] == "" {
+					return newNotification(c, EventVenture, "Venture Complete", "A retainer has completed their venture.", "bike")
+				}
+				return highlighted(newNotification(c, EventVenture, "Venture Complete", fmt.Sprintf("%s has completed their venture.", match[1]), "bike"), match[1])
+			} else if initiator, ok := matchPlayer(lang.ReadyCheck, logLine.Line); ok && c.NotifyOnReadyCheck && !matchesPlayerName(initiator.Name, c.SelfCharacterName) {
+				return forPlayer(newNotification(c, EventReadyCheck, "Ready Check", fmt.Sprintf("%s has started a ready check.", initiator), "persistent"), initiator)
+			} else if c.NotifyOnReadyCheck && strings.Contains(logLine.Line, lang.ReadyCheckDone) {
+				return newNotification(c, EventReadyDone, "Ready Check Complete", logLine.Line, "none")
+			}
+		}
+	case CodeTell:
+		{
+			if !c.NotifyOnTell {
+				break
+			}
+			sender := parsePlayer(logLine.Name)
+			if len(c.TellSenders) > 0 && !matchesAnyPlayerName(sender.Name, c.TellSenders) {
+				break
+			}
+			return forPlayer(newNotification(c, EventTell, "New Tell", fmt.Sprintf("%s: %s", sender, logLine.Line), "pushover"), sender)
+		}
+	case CodeNoviceNetwork:
+		{
+			if !c.NotifyOnNoviceNetwork {
+				break
+			}
+			sender := parsePlayer(logLine.Name)
+			if c.SelfCharacterName != "" && matchesPlayerName(sender.Name, c.SelfCharacterName) {
+				break
+			}
+			if !containsAnyKeyword(logLine.Line, c.NoviceNetworkKeywords) {
+				break
+			}
+			return forPlayer(newNotification(c, EventNovice, "Novice Network", fmt.Sprintf("%s: %s", sender, logLine.Line), "none"), sender)
+		}
+	case CodePartyChange: // join/leave/return to party
+		{
+			if player, ok := matchPlayer(lang.PartyJoin, logLine.Line); ok && c.NotifyOnJoin && shouldNotifyForPartyMember(c, player) {
+				return forPlayer(newNotification(c, EventJoin, "Player Joined Your Party", fmt.Sprintf("%s joined your party.", player), "none"), player)
+			} else if player, ok := matchPlayer(lang.PartyLeave, logLine.Line); ok && c.NotifyOnLeave && !strings.HasPrefix(logLine.Line, lang.PartyLeftSelf) && shouldNotifyForPartyMember(c, player) {
+				return forPlayer(newNotification(c, EventLeave, "Player Left Your Party", fmt.Sprintf("%s left your party.", player), "none"), player)
+			}
+			break
+		}
+	case CodeFCMemberStatus: // free company member login/logout, actions and announcements
+		{
+			login, isLogin := matchPlayer(lang.FCLogin, logLine.Line)
+			logout, isLogout := matchPlayer(lang.FCLogout, logLine.Line)
+			if isLogin && c.NotifyOnFCLogin && shouldNotifyForPartyMember(c, login) {
+				return forPlayer(newNotification(c, EventFCLogin, "FC Member Online", fmt.Sprintf("%s has logged in.", login), "none"), login)
+			} else if isLogout && c.NotifyOnFCLogin && shouldNotifyForPartyMember(c, logout) {
+				return forPlayer(newNotification(c, EventFCLogout, "FC Member Offline", fmt.Sprintf("%s has logged out.", logout), "none"), logout)
+			} else if !isLogin && !isLogout && c.NotifyOnFCAnnouncement && containsAnyKeyword(logLine.Line, c.FCAnnouncementKeywords) {
+				return newNotification(c, EventFCAnnouncement, "FC Announcement", logLine.Line, "none")
+			}
+			break
+		}
+	}
+
+	if isPartyMemberDefeat(logLine.Code) && c.NotifyOnPartyDeath {
+		if player, ok := matchPlayer(lang.PartyDeath, logLine.Line); ok && shouldNotifyForPartyMember(c, player) {
+			return forPlayer(newNotification(c, EventPartyDeath, "Party Member Down", fmt.Sprintf("%s was defeated.", player), "falling"), player)
+		}
+	}
+
+	return buildTriggerNotification(c, logLine)
+}
+
+// containsAnyKeyword returns true if the keywords are empty or the line
+// contains one of them, ignoring case.
+func containsAnyKeyword(line string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	line = strings.ToLower(line)
+	for _, keyword := range keywords {
+		if strings.Contains(line, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateString(input string, limit int) string {
+	runes := []rune(input)
+	if len(runes) <= limit {
+		return input
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// truncateBytes is truncateString for limits given in bytes, it doesn't cut a
+// character in half.
+func truncateBytes(input string, limit int) string {
+	if len(input) <= limit {
+		return input
+	}
+	cut := limit - len("…")
+	for cut > 0 && !utf8.RuneStart(input[cut]) {
+		cut--
+	}
+	return input[:cut] + "…"
+}
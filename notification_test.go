@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildNotification(t *testing.T) {
+	all := Config{NotifyOnFill: true, NotifyOnDisband: true, NotifyOnJoin: true, NotifyOnLeave: true, NotifyOnDutyPop: true, NotifyOnPartyDeath: true}
+	tests := []struct {
+		name   string
+		config Config
+		code   int64
+		line   string
+		// want is the expected event type, empty when nothing should be sent
+		want    string
+		message string
+	}{
+		{"fill", all, CodeSystem, "All party slots have been filled.", EventFill, "All party slots have been filled."},
+		{"fill off", Config{NotifyOnDisband: true}, CodeSystem, "All party slots have been filled.", "", ""},
+		{"disband", all, CodeSystem, "The party has been disbanded.", EventDisband, "The party has been disbanded."},
+		{"disband off", Config{NotifyOnFill: true}, CodeSystem, "The party has been disbanded.", "", ""},
+		{"duty ready", all, CodeSystem, "Duty Finder: Sastasha is ready.", EventDutyPop, "Duty Finder: Sastasha is ready."},
+		{"something else ready", all, CodeSystem, "The cactpot drawing is ready.", "", ""},
+		{"join", all, CodePartyChange, "Kaiyoko Star joins the party.", EventJoin, "Kaiyoko Star joined your party."},
+		{"join off", Config{NotifyOnLeave: true}, CodePartyChange, "Kaiyoko Star joins the party.", "", ""},
+		{"leave", all, CodePartyChange, "Kaiyoko Star has left the party.", EventLeave, "Kaiyoko Star left your party."},
+		{"leave off", Config{NotifyOnJoin: true}, CodePartyChange, "Kaiyoko Star has left the party.", "", ""},
+		{"leave yourself", all, CodePartyChange, "You have left the party.", "", ""},
+		{"cross world join", all, CodePartyChange, "Kaiyoko StarGilgamesh joins the party.", EventJoin, "Kaiyoko Star — Gilgamesh joined your party."},
+		{"party member defeated", all, 0x12BA, "Kaiyoko Star was defeated by the ahriman.", EventPartyDeath, "Kaiyoko Star was defeated."},
+		{"enemy defeated", all, 0x093A, "The ahriman is defeated by Kaiyoko Star.", "", ""},
+		{"unmatched system line", all, CodeSystem, "You sense the presence of a powerful mark...", "", ""},
+		{"unmatched party line", all, CodePartyChange, "You are now the party leader.", "", ""},
+		{"fill text on another code", all, CodeSay, "All party slots have been filled.", "", ""},
+		{"nothing enabled", Config{}, CodeSystem, "All party slots have been filled.", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notification := buildNotification(test.config, LogLine{Time: time.Now(), Code: test.code, Line: test.line})
+			if test.want == "" {
+				if notification != nil {
+					t.Fatalf("expected no notification, got %s: %q", notification.Type, notification.Message)
+				}
+				return
+			}
+			if notification == nil {
+				t.Fatalf("expected a %s notification, got none", test.want)
+			}
+			if notification.Type != test.want {
+				t.Errorf("expected type %s, got %s", test.want, notification.Type)
+			}
+			if notification.Message != test.message {
+				t.Errorf("expected message %q, got %q", test.message, notification.Message)
+			}
+		})
+	}
+}
+
+func TestBuildNotificationClientLanguage(t *testing.T) {
+	c := Config{NotifyOnJoin: true, ClientLanguage: "de"}
+	notification := buildNotification(c, LogLine{Time: time.Now(), Code: CodePartyChange, Line: "Kaiyoko Star ist der Gruppe beigetreten."})
+	if notification == nil || notification.Player.Name != "Kaiyoko Star" {
+		t.Fatalf("expected a join notification for Kaiyoko Star, got %+v", notification)
+	}
+}
+
+func TestParsePlayer(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Player
+	}{
+		{"Kaiyoko Star", Player{Name: "Kaiyoko Star"}},
+		{"Kaiyoko StarGilgamesh", Player{Name: "Kaiyoko Star", World: "Gilgamesh"}},
+		{"Kaiyoko Star@Gilgamesh", Player{Name: "Kaiyoko Star", World: "Gilgamesh"}},
+		{"Kaiyoko StarGilgamesh", Player{Name: "Kaiyoko Star", World: "Gilgamesh"}},
+		{"O'rin Ahn", Player{Name: "O'rin Ahn"}},
+	}
+	for _, test := range tests {
+		if got := parsePlayer(test.raw); got != test.want {
+			t.Errorf("parsePlayer(%q) = %+v, want %+v", test.raw, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const httpTimeout = 10 * time.Second
+const notifyMaxRateLimitWait = time.Minute
+
+// dryRun logs the notifications that would be sent instead of sending them.
+var dryRun = false
+
+// httpClient is shared by all notifiers so that a hung backend can't block
+// delivery indefinitely.
+var httpClient = newHTTPClient()
+
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = notificationProxy
+	return &http.Client{Timeout: httpTimeout, Transport: transport}
+}
+
+// notificationProxy returns the proxy to send the request through, http_proxy
+// when it is set otherwise the proxy from the environment.
+func notificationProxy(req *http.Request) (*url.URL, error) {
+	configLock.RLock()
+	proxy := config.proxy
+	configLock.RUnlock()
+	if proxy == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	return proxy(req.URL)
+}
+
+// backendNames are the names of every backend, as returned by Name.
+var backendNames = []string{"pushover", "pushbullet", "discord", "slack", "telegram", "ntfy", "gotify", "matrix", "mqtt", "webhook", "email", "stdout", "desktop"}
+
+// backendEnabled returns false if the backend has been turned off in
+// backends_enabled, backends are enabled unless listed.
+func backendEnabled(c Config, backend string) bool {
+	enabled, ok := c.BackendsEnabled[backend]
+	return !ok || enabled
+}
+
+// Notifier delivers notifications to a single backend.
+type Notifier interface {
+	// Name identifies the backend in logs and metrics.
+	Name() string
+	Send(ctx context.Context, notification *Notification) error
+}
+
+// buildNotifiers returns a notifier for every backend enabled in the config.
+func buildNotifiers(c Config) []Notifier {
+	out := []Notifier{}
+	if c.PushoverAppToken != "" {
+		// one notifier per user so that a failure for one doesn't stop the
+		// others being sent to
+		for _, userKey := range pushoverUserKeys(c) {
+			out = append(out, &PushoverNotifier{
+				AppToken:        c.PushoverAppToken,
+				UserKey:         userKey,
+				Device:          c.PushoverDevice,
+				Format:          c.PushoverFormat,
+				EmergencyRetry:  c.PushoverEmergencyRetry,
+				EmergencyExpire: c.PushoverEmergencyExpire,
+			})
+		}
+	}
+	if c.PushbulletAccessToken != "" {
+		out = append(out, &PushbulletNotifier{
+			AccessToken: c.PushbulletAccessToken,
+			DeviceIden:  c.PushbulletDeviceIden,
+		})
+	}
+	if c.DiscordWebhookURL != "" {
+		out = append(out, &DiscordNotifier{
+			WebhookURL: c.DiscordWebhookURL,
+			EmbedColor: c.DiscordEmbedColor,
+		})
+	}
+	if c.SlackWebhookURL != "" {
+		out = append(out, &SlackNotifier{
+			WebhookURL: c.SlackWebhookURL,
+		})
+	}
+	if c.TelegramBotToken != "" && c.TelegramChatID != "" {
+		out = append(out, &TelegramNotifier{
+			BotToken: c.TelegramBotToken,
+			ChatID:   c.TelegramChatID,
+		})
+	}
+	if c.NtfyTopic != "" {
+		out = append(out, &NtfyNotifier{
+			Server: c.NtfyServer,
+			Topic:  c.NtfyTopic,
+			Token:  c.NtfyToken,
+		})
+	}
+	if c.GotifyServer != "" && c.GotifyAppToken != "" {
+		out = append(out, &GotifyNotifier{
+			Server:   c.GotifyServer,
+			AppToken: c.GotifyAppToken,
+		})
+	}
+	if c.MatrixHomeserver != "" && c.MatrixAccessToken != "" && c.MatrixRoomID != "" {
+		out = append(out, &MatrixNotifier{
+			Homeserver:  c.MatrixHomeserver,
+			AccessToken: c.MatrixAccessToken,
+			RoomID:      c.MatrixRoomID,
+		})
+	}
+	if c.MqttBroker != "" && c.MqttTopic != "" {
+		out = append(out, &MqttNotifier{
+			Broker:   c.MqttBroker,
+			Topic:    c.MqttTopic,
+			Username: c.MqttUsername,
+			Password: c.MqttPassword,
+		})
+	}
+	if c.WebhookURL != "" {
+		out = append(out, &WebhookNotifier{
+			URL:         c.WebhookURL,
+			ContentType: c.WebhookContentType,
+			Template:    c.webhookTemplate,
+			Secret:      c.WebhookSecret,
+		})
+	}
+	if len(c.SmtpTo) > 0 && c.SmtpHost != "" && c.SmtpFrom != "" {
+		out = append(out, &EmailNotifier{
+			Host:     c.SmtpHost,
+			Port:     c.SmtpPort,
+			User:     c.SmtpUser,
+			Password: c.SmtpPassword,
+			From:     c.SmtpFrom,
+			To:       c.SmtpTo,
+		})
+	}
+	if c.OutputMode == OutputModeStdoutJSON {
+		out = append(out, &StdoutNotifier{})
+	}
+	if c.DesktopNotifications {
+		out = append(out, &DesktopNotifier{})
+	}
+	return out
+}
+
+// StatusError is returned by notifiers when a backend responds with a non 2xx
+// status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAt is when the backend will accept another request, it is zero
+	// when the backend didn't say.
+	RetryAt time.Time
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// sendNotification sends the notification to every notifier, a failure of
+// one does not stop delivery to the others. The returned error combines every
+// failure.
+func sendNotification(ctx context.Context, notifiers []Notifier, notification *Notification) error {
+	configLock.RLock()
+	c := config
+	configLock.RUnlock()
+	if dryRun {
+		for _, notifier := range notifiers {
+			if !backendEnabled(c, notifier.Name()) {
+				continue
+			}
+			slog.Info("Dry run, not sending notification", "backend", notifier.Name(), "type", notification.Type, "title", notification.Title, "message", notification.Message, "sound", notification.Sound, "priority", notification.Priority)
+		}
+		return nil
+	}
+	errs := []error{}
+	for _, notifier := range notifiers {
+		if !backendEnabled(c, notifier.Name()) {
+			slog.Debug("Backend is disabled, not sending notification", "backend", notifier.Name(), "type", notification.Type)
+			continue
+		}
+		start := time.Now()
+		err := withRetry(ctx, retryPolicyFor(c, notifier.Name()), notifier.Name(), func() error {
+			return notifier.Send(ctx, notification)
+		})
+		notificationLatency.WithLabelValues(notifier.Name()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			slog.Error("Failed to deliver notification", "backend", notifier.Name(), "type", notification.Type, "title", notification.Title, "message", notification.Message, "error", err)
+			notificationFailures.WithLabelValues(notification.Type, notifier.Name()).Inc()
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("Sent notification", "backend", notifier.Name(), "type", notification.Type, "title", notification.Title)
+		notificationsSent.WithLabelValues(notification.Type, notifier.Name()).Inc()
+	}
+	err := errors.Join(errs...)
+	recordNotification(notification, err)
+	return err
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, data interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return client.Do(req)
+}
+
+// postMultipart posts the fields and the file as multipart/form-data, the file
+// is sent as the given field.
+func postMultipart(ctx context.Context, client *http.Client, url string, fields map[string]string, fileField string, path string) (*http.Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := writer.CreateFormFile(fileField, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return client.Do(req)
+}
+
+func isSuccessStatus(resp *http.Response) bool {
+	return resp.StatusCode >= 200 && resp.StatusCode <= 299
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		statusErr.RetryAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return statusErr
+}
+
+func checkResponse(resp *http.Response) error {
+	if !isSuccessStatus(resp) {
+		return newStatusError(resp)
+	}
+	return nil
+}
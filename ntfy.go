@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultNtfyServer = "https://ntfy.sh"
+
+// ntfyMessageLimit is in bytes, ntfy turns longer messages into an attachment.
+const ntfyMessageLimit = 4096
+const ntfyTitleLimit = 250
+
+// ntfyTagForSound maps a Pushover sound to an ntfy tag, which ntfy renders as
+// an emoji in front of the title.
+func ntfyTagForSound(sound string) string {
+	if sound == "" || sound == "none" {
+		return "mute"
+	}
+	return "loud_sound"
+}
+
+type NtfyNotifier struct {
+	Server string
+	Topic  string
+	Token  string
+}
+
+func (n *NtfyNotifier) Name() string {
+	return "ntfy"
+}
+
+func (n *NtfyNotifier) Send(ctx context.Context, notification *Notification) error {
+	topicUrl := strings.TrimRight(n.Server, "/") + "/" + n.Topic
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, topicUrl, strings.NewReader(truncateBytes(notification.Message, ntfyMessageLimit)))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	req.Header.Set("Title", truncateString(notification.Title, ntfyTitleLimit))
+	req.Header.Set("Tags", ntfyTagForSound(notification.Sound))
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const pushbulletPushesUrl = "https://api.pushbullet.com/v2/pushes"
+
+type PushbulletPush struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	DeviceIden string `json:"device_iden,omitempty"`
+}
+
+type PushbulletError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type PushbulletNotifier struct {
+	AccessToken string
+	// DeviceIden limits delivery to one device, empty for all devices.
+	DeviceIden string
+}
+
+func (n *PushbulletNotifier) Name() string {
+	return "pushbullet"
+}
+
+func (n *PushbulletNotifier) Send(ctx context.Context, notification *Notification) error {
+	jsonData, err := json.Marshal(PushbulletPush{
+		Type:       "note",
+		Title:      notification.Title,
+		Body:       notification.Message,
+		DeviceIden: n.DeviceIden,
+	})
+	if err != nil {
+		return fmt.Errorf("pushbullet: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushbulletPushesUrl, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("pushbullet: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Access-Token", n.AccessToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushbullet: %w", err)
+	}
+	defer resp.Body.Close()
+	if isSuccessStatus(resp) {
+		return nil
+	}
+	statusErr := newStatusError(resp)
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64); err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		statusErr.RetryAt = time.Unix(reset, 0)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	pushbulletErr := PushbulletError{}
+	if json.Unmarshal(body, &pushbulletErr) == nil && pushbulletErr.Error.Message != "" {
+		return fmt.Errorf("pushbullet: %s: %w", pushbulletErr.Error.Message, statusErr)
+	}
+	return fmt.Errorf("pushbullet: %w", statusErr)
+}
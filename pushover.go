@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const messageUrl = "https://api.pushover.net/1/messages.json"
+
+const pushoverEmergencyPriority = 2
+
+// longer titles and messages are cut off by Pushover
+const pushoverTitleLimit = 250
+const pushoverMessageLimit = 1024
+
+// pushoverAttachmentLimit is the largest image Pushover accepts.
+const pushoverAttachmentLimit = 5 * 1024 * 1024
+
+const (
+	PushoverFormatPlain     = ""
+	PushoverFormatHTML      = "html"
+	PushoverFormatMonospace = "monospace"
+)
+
+type PushoverNotifier struct {
+	AppToken string
+	UserKey  string
+	// Device limits delivery to the named device, empty for all devices.
+	Device string
+	// Format is one of the PushoverFormat constants.
+	Format string
+	// Client and URL default to the shared client and Pushover's API, they
+	// can be replaced to send to a test server instead.
+	Client *http.Client
+	URL    string
+	// EmergencyRetry and EmergencyExpire are how often, and for how long, in
+	// seconds Pushover repeats emergency priority notifications.
+	EmergencyRetry  int
+	EmergencyExpire int
+}
+
+type PushoverResponse struct {
+	Status  int      `json:"status"`
+	Request string   `json:"request"`
+	Errors  []string `json:"errors"`
+}
+
+// pushoverSounds are the sounds built in to Pushover, others are silently
+// replaced with the default sound.
+var pushoverSounds = []string{
+	"pushover", "bike", "bugle", "cashregister", "classical", "cosmic",
+	"falling", "gamelan", "incoming", "intermission", "magic", "mechanical",
+	"pianobar", "siren", "spacealarm", "tugboat", "alien", "climb",
+	"persistent", "echo", "updown", "vibrate", "none",
+}
+
+// checkPushoverSound returns an error if the sound isn't a built in or
+// custom sound.
+func checkPushoverSound(sound string, customSounds []string) error {
+	if sound == "" || slices.Contains(pushoverSounds, sound) || slices.Contains(customSounds, sound) {
+		return nil
+	}
+	return fmt.Errorf("%s is not a Pushover sound, use one of %s or add it to pushover_custom_sounds", sound, strings.Join(pushoverSounds, ", "))
+}
+
+// pushoverUserKeys returns every user or group key to send to.
+func pushoverUserKeys(c Config) []string {
+	out := []string{}
+	for _, userKey := range append([]string{c.PushoverUserKey}, c.PushoverUserKeys...) {
+		if userKey != "" {
+			out = append(out, userKey)
+		}
+	}
+	return out
+}
+
+// pushoverMessage returns the notification's message for the format, in html
+// the message is escaped and its highlight is bold. Escaping makes the message
+// longer, so in html it's cut until it fits once escaped rather than cutting
+// the escaped message and maybe an entity or tag with it.
+func pushoverMessage(notification *Notification, format string) string {
+	if format != PushoverFormatHTML {
+		return truncateString(notification.Message, pushoverMessageLimit)
+	}
+	runes := []rune(notification.Message)
+	message := pushoverHTML(notification.Message, notification.Highlight)
+	for cut := min(len(runes), pushoverMessageLimit); utf8.RuneCountInString(message) > pushoverMessageLimit; cut-- {
+		message = pushoverHTML(string(runes[:cut-1])+"…", notification.Highlight)
+	}
+	return message
+}
+
+// pushoverHTML escapes the message and makes the first match of the highlight
+// in it bold.
+func pushoverHTML(message string, highlight string) string {
+	message = html.EscapeString(message)
+	if highlight != "" {
+		highlight = html.EscapeString(highlight)
+		message = strings.Replace(message, highlight, "<b>"+highlight+"</b>", 1)
+	}
+	return message
+}
+
+func (n *PushoverNotifier) Name() string {
+	return "pushover"
+}
+
+func (n *PushoverNotifier) Send(ctx context.Context, notification *Notification) error {
+	data := map[string]string{
+		"token":   n.AppToken,
+		"user":    n.UserKey,
+		"title":   truncateString(notification.Title, pushoverTitleLimit),
+		"message": pushoverMessage(notification, n.Format),
+		"sound":   notification.Sound,
+	}
+	if notification.URL != "" {
+		data["url"] = notification.URL
+	}
+	if notification.URLTitle != "" {
+		data["url_title"] = notification.URLTitle
+	}
+	switch n.Format {
+	case PushoverFormatHTML:
+		data["html"] = "1"
+	case PushoverFormatMonospace:
+		data["monospace"] = "1"
+	}
+	if n.Device != "" {
+		data["device"] = n.Device
+	}
+	if notification.Priority != 0 {
+		data["priority"] = strconv.Itoa(notification.Priority)
+	}
+	if notification.Priority == pushoverEmergencyPriority {
+		data["retry"] = strconv.Itoa(n.EmergencyRetry)
+		data["expire"] = strconv.Itoa(n.EmergencyExpire)
+	}
+	client, url := n.Client, n.URL
+	if client == nil {
+		client = httpClient
+	}
+	if url == "" {
+		url = messageUrl
+	}
+	// files can't be sent as json
+	var resp *http.Response
+	var err error
+	if notification.Attachment != "" {
+		resp, err = postMultipart(ctx, client, url, data, "attachment", notification.Attachment)
+	} else {
+		resp, err = postJSON(ctx, client, url, data)
+	}
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	pushoverResp := PushoverResponse{}
+	decodeErr := json.Unmarshal(body, &pushoverResp)
+	if !isSuccessStatus(resp) {
+		statusErr := newStatusError(resp)
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-Limit-App-Reset"), 10, 64); err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			statusErr.RetryAt = time.Unix(reset, 0)
+		}
+		if len(pushoverResp.Errors) > 0 {
+			return fmt.Errorf("pushover: %s: %w", strings.Join(pushoverResp.Errors, ", "), statusErr)
+		}
+		return fmt.Errorf("pushover: %w", statusErr)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("pushover: unable to decode response: %w", decodeErr)
+	}
+	if pushoverResp.Status != 1 {
+		if len(pushoverResp.Errors) > 0 {
+			return fmt.Errorf("pushover: %s", strings.Join(pushoverResp.Errors, ", "))
+		}
+		return fmt.Errorf("pushover: unexpected response status %d", pushoverResp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newPushoverServer returns a server that records the content type and body
+// of every request and responds with the given status and body.
+func newPushoverServer(t *testing.T, status int, body string, contentTypes *[]string, bodies *[][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unable to read request body: %s", err)
+		}
+		*contentTypes = append(*contentTypes, r.Header.Get("Content-Type"))
+		*bodies = append(*bodies, data)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPushoverSendPayload(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusOK, `{"status":1,"request":"abc"}`, &contentTypes, &bodies)
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "user", Client: server.Client(), URL: server.URL}
+	notification := &Notification{Title: "Your Party Has Filled", Message: "All party slots have been filled.", Sound: "gamelan"}
+	if err := notifier.Send(context.Background(), notification); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(bodies))
+	}
+	if contentType := contentTypes[0]; contentType != "application/json" {
+		t.Errorf("expected a json request, got %s", contentType)
+	}
+	payload := map[string]string{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("unable to decode payload: %s", err)
+	}
+	want := map[string]string{
+		"token":   "app",
+		"user":    "user",
+		"title":   "Your Party Has Filled",
+		"message": "All party slots have been filled.",
+		"sound":   "gamelan",
+	}
+	if len(payload) != len(want) {
+		t.Errorf("expected payload %v, got %v", want, payload)
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, payload[key])
+		}
+	}
+}
+
+func TestPushoverSendOptionalFields(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusOK, `{"status":1}`, &contentTypes, &bodies)
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "user", Device: "phone", Format: PushoverFormatHTML, EmergencyRetry: 60, EmergencyExpire: 3600, Client: server.Client(), URL: server.URL}
+	notification := &Notification{Title: "Tell", Message: "A <b> & B", Priority: 2, URL: "https://example.com", URLTitle: "Example", Highlight: "A"}
+	if err := notifier.Send(context.Background(), notification); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	payload := map[string]string{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("unable to decode payload: %s", err)
+	}
+	want := map[string]string{
+		"device":    "phone",
+		"html":      "1",
+		"message":   "<b>A</b> &lt;b&gt; &amp; B",
+		"priority":  "2",
+		"retry":     "60",
+		"expire":    "3600",
+		"url":       "https://example.com",
+		"url_title": "Example",
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, payload[key])
+		}
+	}
+}
+
+func TestPushoverSendTruncates(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusOK, `{"status":1}`, &contentTypes, &bodies)
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "user", Client: server.Client(), URL: server.URL}
+	notification := &Notification{Title: strings.Repeat("t", 300), Message: strings.Repeat("m", 2000)}
+	if err := notifier.Send(context.Background(), notification); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	payload := map[string]string{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("unable to decode payload: %s", err)
+	}
+	if length := len([]rune(payload["title"])); length != pushoverTitleLimit {
+		t.Errorf("expected the title to be cut to %d characters, got %d", pushoverTitleLimit, length)
+	}
+	if length := len([]rune(payload["message"])); length != pushoverMessageLimit {
+		t.Errorf("expected the message to be cut to %d characters, got %d", pushoverMessageLimit, length)
+	}
+}
+
+func TestPushoverSendTruncatesHTML(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusOK, `{"status":1}`, &contentTypes, &bodies)
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "user", Format: PushoverFormatHTML, Client: server.Client(), URL: server.URL}
+	notification := &Notification{Title: "Test", Message: "A" + strings.Repeat("<", 2000), Highlight: "A"}
+	if err := notifier.Send(context.Background(), notification); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	payload := map[string]string{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("unable to decode payload: %s", err)
+	}
+	message := payload["message"]
+	if length := len([]rune(message)); length > pushoverMessageLimit {
+		t.Errorf("expected the escaped message to be at most %d characters, got %d", pushoverMessageLimit, length)
+	}
+	// what's left once the tag, whole entities and the ellipsis are removed
+	// shows whether anything was cut in half
+	rest := strings.TrimSuffix(strings.TrimPrefix(message, "<b>A</b>"), "…")
+	if !strings.HasSuffix(message, "…") || strings.ReplaceAll(rest, "&lt;", "") != "" {
+		t.Errorf("expected the message cut between entities, got %q", message)
+	}
+}
+
+func TestPushoverSendErrors(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusBadRequest, `{"status":0,"errors":["user identifier is invalid"]}`, &contentTypes, &bodies)
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "bad", Client: server.Client(), URL: server.URL}
+	err := notifier.Send(context.Background(), &Notification{Title: "Test", Message: "Test"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "user identifier is invalid") {
+		t.Errorf("expected the error to include Pushover's errors, got %s", err)
+	}
+	statusErr := &StatusError{}
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 StatusError, got %v", err)
+	}
+}
+
+func TestPushoverSendAttachment(t *testing.T) {
+	var contentTypes []string
+	var bodies [][]byte
+	server := newPushoverServer(t, http.StatusOK, `{"status":1}`, &contentTypes, &bodies)
+	path := filepath.Join(t.TempDir(), "fill.png")
+	if err := os.WriteFile(path, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &PushoverNotifier{AppToken: "app", UserKey: "user", Client: server.Client(), URL: server.URL}
+	if err := notifier.Send(context.Background(), &Notification{Title: "Test", Message: "Test", Attachment: path}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentTypes[0])
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("expected a multipart request, got %q", contentTypes[0])
+	}
+	form, err := multipart.NewReader(bytes.NewReader(bodies[0]), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("unable to read the multipart form: %s", err)
+	}
+	if token := form.Value["token"]; len(token) != 1 || token[0] != "app" {
+		t.Errorf("expected token app, got %q", token)
+	}
+	files := form.File["attachment"]
+	if len(files) != 1 || files[0].Filename != "fill.png" {
+		t.Fatalf("expected fill.png attached, got %v", files)
+	}
+}
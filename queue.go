@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const notificationQueueSize = 100
+const notificationWorkers = 4
+
+// QueuedNotification is a notification waiting to be sent to the notifiers
+// that were current when it was built.
+type QueuedNotification struct {
+	Notifiers    []Notifier
+	Notification *Notification
+}
+
+// notificationQueue decouples reading log lines from delivering notifications
+// so that a slow backend doesn't hold up reading. With more than one worker
+// notifications close together may be delivered out of order.
+var notificationQueue = make(chan QueuedNotification, notificationQueueSize)
+
+// queueNotification queues the notification to be sent, dropping the oldest
+// queued notification if the queue is full.
+func queueNotification(notifiers []Notifier, notification *Notification) {
+	queued := QueuedNotification{Notifiers: notifiers, Notification: notification}
+	for {
+		select {
+		case notificationQueue <- queued:
+			if flushing.Load() {
+				queuedWhileFlushing.Add(1)
+			}
+			return
+		default:
+		}
+		select {
+		case dropped := <-notificationQueue:
+			slog.Warn("Notification queue is full, dropping the oldest notification", "type", dropped.Notification.Type, "title", dropped.Notification.Title)
+			notificationsDropped.Inc()
+		default:
+		}
+	}
+}
+
+var (
+	// stopWorkers is closed to have the workers send what's left in the queue
+	// and return.
+	stopWorkers = make(chan struct{})
+	workers     sync.WaitGroup
+	// sendCtx is cancelled to abandon sends still going once the shutdown
+	// timeout has passed.
+	sendCtx, cancelSends = context.WithCancel(context.Background())
+	// flushing is set once flushNotifications has been called, from then on
+	// queuedWhileFlushing counts the notifications queued, such as held joins
+	// and leaves and the digest, and flushedCount those sent successfully.
+	flushing            atomic.Bool
+	queuedWhileFlushing atomic.Int32
+	flushedCount        atomic.Int32
+)
+
+// startNotificationWorkers starts the workers that send queued notifications
+// until flushNotifications is called.
+func startNotificationWorkers() {
+	for i := 0; i < notificationWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case queued := <-notificationQueue:
+					sendQueued(queued)
+				case <-stopWorkers:
+					drainNotifications()
+					return
+				}
+			}
+		}()
+	}
+}
+
+// drainNotifications sends queued notifications until the queue is empty or
+// the sends are abandoned.
+func drainNotifications() {
+	for sendCtx.Err() == nil {
+		select {
+		case queued := <-notificationQueue:
+			sendQueued(queued)
+		default:
+			return
+		}
+	}
+}
+
+// sendQueued sends a queued notification, counting it in flushedCount if it
+// was taken off the queue after flushing started.
+func sendQueued(queued QueuedNotification) {
+	counted := flushing.Load()
+	if sendNotification(sendCtx, queued.Notifiers, queued.Notification) == nil && counted {
+		flushedCount.Add(1)
+	}
+}
+
+// flushNotifications sends any held joins and leaves, any pending digest and
+// everything still queued, giving up on whatever hasn't been sent once the
+// timeout has passed.
+func flushNotifications(timeout time.Duration) {
+	flushing.Store(true)
+	queued := len(notificationQueue)
+	rejoins.Flush()
+	digest.Flush()
+	close(stopWorkers)
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		cancelSends()
+		<-done
+	}
+	queued += int(queuedWhileFlushing.Load())
+	flushed := int(flushedCount.Load())
+	if queued == 0 && flushed == 0 {
+		return
+	}
+	slog.Info("Flushed queued notifications before exiting", "flushed", flushed, "dropped", max(queued-flushed, 0))
+}
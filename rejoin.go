@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RejoinBuffer holds join and leave notifications for a moment so that a
+// player leaving and straight away rejoining, as happens when they briefly
+// disconnect, is dropped instead of sent as a misleading pair.
+type RejoinBuffer struct {
+	lock    sync.Mutex
+	pending map[string]*heldPartyChange
+}
+
+type heldPartyChange struct {
+	notification *Notification
+	timer        *time.Timer
+	send         func()
+}
+
+var rejoins = &RejoinBuffer{pending: map[string]*heldPartyChange{}}
+
+// isPartyChange returns true for the notifications held by the RejoinBuffer.
+func isPartyChange(notification *Notification) bool {
+	return (notification.Type == EventJoin || notification.Type == EventLeave) && notification.Player.Name != ""
+}
+
+// Hold calls send once the window has elapsed, unless the opposite join or
+// leave for the same player arrives first in which case both are dropped.
+// Whichever of the timer and Flush removes the held notification from pending
+// sends it, with the lock held so that Flush returns only once it's sent.
+func (b *RejoinBuffer) Hold(notification *Notification, window time.Duration, send func()) {
+	key := strings.ToLower(notification.Source + "\x00" + notification.Player.Name)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if held, ok := b.pending[key]; ok && held.notification.Type != notification.Type {
+		held.timer.Stop()
+		delete(b.pending, key)
+		slog.Debug("Dropped a leave and rejoin of the same player", "player", notification.Player.Name, "first", held.notification.Type, "second", notification.Type)
+		return
+	}
+	held := &heldPartyChange{notification: notification, send: send}
+	held.timer = time.AfterFunc(window, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if b.pending[key] == held {
+			delete(b.pending, key)
+			send()
+		}
+	})
+	b.pending[key] = held
+}
+
+// Flush sends every held notification straight away.
+func (b *RejoinBuffer) Flush() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for key, held := range b.pending {
+		held.timer.Stop()
+		delete(b.pending, key)
+		held.send()
+	}
+}
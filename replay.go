@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+)
+
+const replayMaxLineLength = 1024 * 1024
+
+// replayLogFile prints the notifications that would be sent for every line
+// of a captured log, without sending anything.
+func replayLogFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, replayMaxLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		logLine := readLogLing(scanner.Text())
+		notification := buildNotification(config, logLine)
+		if notification == nil {
+			logUnmatched(context.Background(), logLine)
+			continue
+		}
+		count++
+		fmt.Printf("line %d: [%s] %s: %s (sound %s, priority %d)\n", lineNumber, notification.Type, notification.Title, notification.Message, notification.Sound, notification.Priority)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("%d notifications would have been sent.\n", count)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+const defaultNotifyRetryMax = 2
+const defaultNotifyRetryBaseMs = 1000
+const defaultNotifyRetryJitter = 0.2
+
+// RetryPolicyConfig overrides the notify_retry_ settings for one backend,
+// anything left unset uses the global setting.
+type RetryPolicyConfig struct {
+	Max    *int     `yaml:"max"`
+	BaseMs int      `yaml:"base_ms"`
+	Jitter *float64 `yaml:"jitter"`
+}
+
+// RetryPolicy is how a failed send is retried. The delay doubles after every
+// attempt and is reduced by a random amount up to Jitter of it so that
+// retries to the same backend don't line up.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	Jitter     float64
+}
+
+// retryPolicyFor returns the retry policy for the backend under the given
+// config.
+func retryPolicyFor(c Config, backend string) RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries: *c.NotifyRetryMax,
+		BaseDelay:  time.Duration(c.NotifyRetryBaseMs) * time.Millisecond,
+		Jitter:     *c.NotifyRetryJitter,
+	}
+	override, ok := c.RetryPolicies[backend]
+	if !ok {
+		return policy
+	}
+	if override.Max != nil {
+		policy.MaxRetries = *override.Max
+	}
+	if override.BaseMs > 0 {
+		policy.BaseDelay = time.Duration(override.BaseMs) * time.Millisecond
+	}
+	if override.Jitter != nil {
+		policy.Jitter = *override.Jitter
+	}
+	return policy
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > notifyMaxRateLimitWait {
+		delay = notifyMaxRateLimitWait
+	}
+	return delay - time.Duration(rand.Float64()*p.Jitter*float64(delay))
+}
+
+// retryDelay returns how long to wait before the given retry after the error,
+// or false if retrying is pointless.
+func (p RetryPolicy) retryDelay(err error, retry int) (time.Duration, bool) {
+	delay := p.backoff(retry)
+	// permanent SMTP errors, such as a rejected login, won't go away
+	smtpErr := &textproto.Error{}
+	if errors.As(err, &smtpErr) {
+		return delay, smtpErr.Code < 500
+	}
+	statusErr := &StatusError{}
+	if !errors.As(err, &statusErr) {
+		return delay, true
+	}
+	if !statusErr.RetryAt.IsZero() {
+		wait := time.Until(statusErr.RetryAt)
+		return max(wait, delay), wait <= notifyMaxRateLimitWait
+	}
+	if statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return delay, true
+}
+
+// withRetry calls send until it succeeds, the policy gives up or the context
+// is cancelled, returning the last error.
+func withRetry(ctx context.Context, policy RetryPolicy, backend string, send func() error) error {
+	for retry := 1; ; retry++ {
+		err := send()
+		if err == nil {
+			return nil
+		}
+		if retry > policy.MaxRetries {
+			return err
+		}
+		delay, ok := policy.retryDelay(err, retry)
+		if !ok {
+			return err
+		}
+		slog.Warn("Unable to send notification, retrying", "backend", backend, "retry", retry, "max_retries", policy.MaxRetries, "retry_in", delay.Round(time.Millisecond), "error", err)
+		notificationRetries.WithLabelValues(backend).Inc()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const slackHeaderLimit = 150
+const slackSectionLimit = 3000
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackBlock struct {
+	Type string    `json:"type"`
+	Text SlackText `json:"text"`
+}
+
+type SlackMessage struct {
+	// Text is shown in push notifications, which don't render blocks.
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Send(ctx context.Context, notification *Notification) error {
+	message := SlackMessage{
+		Text: notification.Title,
+		Blocks: []SlackBlock{
+			{Type: "header", Text: SlackText{Type: "plain_text", Text: truncateString(notification.Title, slackHeaderLimit)}},
+		},
+	}
+	if notification.Message != "" {
+		message.Blocks = append(message.Blocks, SlackBlock{
+			Type: "section",
+			Text: SlackText{Type: "plain_text", Text: truncateString(notification.Message, slackSectionLimit)},
+		})
+	}
+	resp, err := postJSON(ctx, httpClient, n.WebhookURL, message)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if !isSuccessStatus(resp) {
+		// slack responds with the reason as plain text, e.g. invalid_payload
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if reason := strings.TrimSpace(string(body)); reason != "" {
+			return fmt.Errorf("slack: %s: %w", reason, newStatusError(resp))
+		}
+		return fmt.Errorf("slack: %w", newStatusError(resp))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebsocketSource is an ACT or overlay websocket server to read log lines
+// from. Name, when set, is put in front of the title of its notifications so
+// that you can tell sources apart.
+type WebsocketSource struct {
+	Name               string `yaml:"name"`
+	Host               string `yaml:"host"`
+	Port               int    `yaml:"port"`
+	Path               string `yaml:"path"`
+	TLS                bool   `yaml:"tls"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// SubscribeEvents are the OverlayPlugin events to subscribe to after
+	// connecting, MiniParse sends chat without subscribing.
+	SubscribeEvents []string `yaml:"subscribe_events"`
+}
+
+// SubscribeMessage asks an OverlayPlugin server to send the named events.
+type SubscribeMessage struct {
+	Call   string   `json:"call"`
+	Events []string `json:"events"`
+}
+
+func (s WebsocketSource) URL() url.URL {
+	scheme := "ws"
+	if s.TLS {
+		scheme = "wss"
+	}
+	return url.URL{Scheme: scheme, Host: net.JoinHostPort(s.Host, strconv.Itoa(s.Port)), Path: s.Path}
+}
+
+func (s WebsocketSource) Dialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if s.InsecureSkipVerify {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &dialer
+}
+
+// subscribe sends the subscription for the source's events, if it has any.
+func (s WebsocketSource) subscribe(c *websocket.Conn) error {
+	if len(s.SubscribeEvents) == 0 {
+		return nil
+	}
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	defer c.SetWriteDeadline(time.Time{})
+	return c.WriteJSON(SubscribeMessage{Call: "subscribe", Events: s.SubscribeEvents})
+}
+
+// websocketSources returns the configured websocket_sources, or a single
+// source made from the websocket_ settings when there are none.
+func websocketSources(c Config) []WebsocketSource {
+	if len(c.WebsocketSources) > 0 {
+		return c.WebsocketSources
+	}
+	return []WebsocketSource{{
+		Host:               c.WebsocketHost,
+		Port:               c.WebsocketPort,
+		Path:               c.WebsocketPath,
+		TLS:                c.WebsocketTLS,
+		InsecureSkipVerify: c.WebsocketInsecureSkipVerify,
+		SubscribeEvents:    c.SubscribeEvents,
+	}}
+}
+
+// checkWebsocketHost returns an error if the host can't be connected to, the
+// setting is named in the error.
+func checkWebsocketHost(setting string, host string) error {
+	if host != "" && strings.TrimSpace(host) == "" {
+		return fmt.Errorf("%s is blank, remove it to use the default of %s", setting, defaultWebsocketHost)
+	}
+	if strings.Contains(host, "://") {
+		return fmt.Errorf("%s must be a host name or IP address, not a URL (%s)", setting, host)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const OutputModeStdoutJSON = "stdout-json"
+
+// StdoutNotifier writes notifications to stdout as JSON lines so they can be
+// piped in to other programs. Logs go to stderr so they don't get mixed in.
+type StdoutNotifier struct{}
+
+type StdoutLine struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Sound    string    `json:"sound"`
+	Priority int       `json:"priority"`
+	Player   string    `json:"player,omitempty"`
+	World    string    `json:"world,omitempty"`
+	Source   string    `json:"source,omitempty"`
+}
+
+// stdoutLock stops lines from concurrent sends interleaving.
+var stdoutLock sync.Mutex
+
+func (n *StdoutNotifier) Name() string {
+	return "stdout"
+}
+
+func (n *StdoutNotifier) Send(ctx context.Context, notification *Notification) error {
+	line, err := json.Marshal(StdoutLine{
+		Time:     time.Now(),
+		Type:     notification.Type,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Sound:    notification.Sound,
+		Priority: notification.Priority,
+		Player:   notification.Player.Name,
+		World:    notification.Player.World,
+		Source:   notification.Source,
+	})
+	if err != nil {
+		return fmt.Errorf("stdout: %w", err)
+	}
+	stdoutLock.Lock()
+	defer stdoutLock.Unlock()
+	if _, err := os.Stdout.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("stdout: %w", err)
+	}
+	return nil
+}
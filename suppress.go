@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// dedupMaxEntries caps how many recent notifications are remembered.
+const dedupMaxEntries = 50
+
+// recentNotifications maps a hash of recently sent notifications to when they
+// were sent.
+var recentNotifications = map[[sha256.Size]byte]time.Time{}
+
+// lastSentByType maps an event type to when a notification of it was last
+// allowed through, for the per type cooldowns.
+var lastSentByType = map[string]time.Time{}
+
+// soundPlayed holds the event types that have played their sound, for
+// sound_once.
+var soundPlayed = map[string]bool{}
+
+// suppressLock guards the state used to decide if a notification is
+// suppressed.
+var suppressLock sync.Mutex
+
+// parseClockTime parses a 24 hour HH:MM time into minutes after midnight.
+func parseClockTime(input string) (int, error) {
+	clock, err := time.Parse("15:04", input)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a 24 hour HH:MM time", input)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// inQuietHours returns true if the time, in the configured timezone, falls in
+// the configured quiet hours. The window may wrap past midnight, e.g. 23:00 to
+// 07:00.
+func inQuietHours(now time.Time) bool {
+	if config.QuietHoursStart == "" || config.QuietHoursEnd == "" {
+		return false
+	}
+	now = now.In(config.timeLocation())
+	start, _ := parseClockTime(config.QuietHoursStart)
+	end, _ := parseClockTime(config.QuietHoursEnd)
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// isDuplicate returns true if an identical notification was seen within the
+// dedup window, otherwise the notification is remembered.
+func isDuplicate(notification *Notification, now time.Time) bool {
+	window := time.Duration(*config.DedupWindowSeconds) * time.Second
+	if window <= 0 {
+		return false
+	}
+	var oldestKey [sha256.Size]byte
+	oldest := now
+	for key, seen := range recentNotifications {
+		if now.Sub(seen) >= window {
+			delete(recentNotifications, key)
+			continue
+		}
+		if seen.Before(oldest) {
+			oldestKey, oldest = key, seen
+		}
+	}
+	key := sha256.Sum256([]byte(notification.Title + "\x00" + notification.Message))
+	if _, ok := recentNotifications[key]; ok {
+		return true
+	}
+	if len(recentNotifications) >= dedupMaxEntries {
+		delete(recentNotifications, oldestKey)
+	}
+	recentNotifications[key] = now
+	return false
+}
+
+// onCooldown returns true if a notification of the same type was allowed
+// through within the type's cooldown, otherwise the time is remembered.
+func onCooldown(notification *Notification, now time.Time) bool {
+	seconds, ok := config.Cooldowns[notification.Type]
+	if !ok {
+		seconds = defaultCooldowns[notification.Type]
+	}
+	cooldown := time.Duration(seconds) * time.Second
+	if cooldown <= 0 {
+		return false
+	}
+	if last, ok := lastSentByType[notification.Type]; ok && now.Sub(last) < cooldown {
+		return true
+	}
+	lastSentByType[notification.Type] = now
+	return false
+}
+
+// silenceRepeatSound, when sound_once is set, replaces the sound with none
+// for event types that have already played theirs.
+func silenceRepeatSound(notification *Notification) {
+	if !config.SoundOnce || notification.Sound == "" || notification.Sound == "none" {
+		return
+	}
+	suppressLock.Lock()
+	defer suppressLock.Unlock()
+	if soundPlayed[notification.Type] {
+		notification.Sound = "none"
+		return
+	}
+	soundPlayed[notification.Type] = true
+}
+
+// shouldSuppress returns true, logging why, if the notification shouldn't be
+// sent right now.
+func shouldSuppress(notification *Notification, now time.Time) bool {
+	suppressLock.Lock()
+	defer suppressLock.Unlock()
+	if inQuietHours(now) && !slices.Contains(config.QuietHoursExempt, notification.Type) {
+		slog.Debug("Suppressed notification during quiet hours", "type", notification.Type, "title", notification.Title)
+		return true
+	}
+	if onCooldown(notification, now) {
+		slog.Debug("Suppressed notification during its type's cooldown", "type", notification.Type, "title", notification.Title)
+		return true
+	}
+	if isDuplicate(notification, now) {
+		slog.Debug("Suppressed duplicate notification", "type", notification.Type, "title", notification.Title)
+		return true
+	}
+	return false
+}
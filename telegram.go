@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const telegramMessageUrl = "https://api.telegram.org/bot%s/sendMessage"
+
+// telegramTextLimit is the most characters a message can have, counted after
+// the escapes are parsed so the title and message are truncated before
+// escaping them.
+const telegramTextLimit = 4096
+const telegramTitleLimit = 256
+
+// telegramEscaper escapes the characters reserved by Telegram's MarkdownV2.
+var telegramEscaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(",
+	")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+",
+	"-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.",
+	"!", "\\!",
+)
+
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string
+}
+
+func (n *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (n *TelegramNotifier) Send(ctx context.Context, notification *Notification) error {
+	title := truncateString(notification.Title, telegramTitleLimit)
+	message := truncateString(notification.Message, telegramTextLimit-utf8.RuneCountInString(title)-1)
+	data := map[string]string{
+		"chat_id":    n.ChatID,
+		"text":       fmt.Sprintf("*%s*\n%s", telegramEscaper.Replace(title), telegramEscaper.Replace(message)),
+		"parse_mode": "MarkdownV2",
+	}
+	resp, err := postJSON(ctx, httpClient, fmt.Sprintf(telegramMessageUrl, n.BotToken), data)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// EventTemplateData is what title and message templates are rendered with.
+// Title and Message are the defaults for the event, Line is the raw log line.
+type EventTemplateData struct {
+	Type    string
+	Title   string
+	Message string
+	Line    string
+	Player  string
+	World   string
+	Source  string
+}
+
+// parseEventTemplates parses the template for each event type and renders
+// them once so that references to unknown fields are caught early.
+func parseEventTemplates(input map[string]string) (map[string]*template.Template, error) {
+	out := map[string]*template.Template{}
+	for event, text := range input {
+		tmpl, err := template.New(event).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", event, err)
+		}
+		if err := tmpl.Execute(io.Discard, EventTemplateData{}); err != nil {
+			return nil, fmt.Errorf("%s: %w", event, err)
+		}
+		out[event] = tmpl
+	}
+	return out, nil
+}
+
+func renderEventTemplate(tmpl *template.Template, data EventTemplateData) (string, error) {
+	out := bytes.Buffer{}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// applyEventTemplates replaces the notification's title and message with
+// its event type's templates, if it has them, keeping the prefixes.
+func applyEventTemplates(c Config, notification *Notification, line string) error {
+	titleTemplate, hasTitle := c.titleTemplates[notification.Type]
+	messageTemplate, hasMessage := c.messageTemplates[notification.Type]
+	if !hasTitle && !hasMessage {
+		return nil
+	}
+	titlePrefix := c.TitlePrefixes[notification.Type]
+	messagePrefix := c.MessagePrefixes[notification.Type]
+	data := EventTemplateData{
+		Type:    notification.Type,
+		Title:   strings.TrimPrefix(notification.Title, titlePrefix),
+		Message: strings.TrimPrefix(notification.Message, messagePrefix),
+		Line:    line,
+		Player:  notification.Player.Name,
+		World:   notification.Player.World,
+		Source:  notification.Source,
+	}
+	if hasTitle {
+		title, err := renderEventTemplate(titleTemplate, data)
+		if err != nil {
+			return fmt.Errorf("title: %w", err)
+		}
+		notification.Title = titlePrefix + title
+	}
+	if hasMessage {
+		message, err := renderEventTemplate(messageTemplate, data)
+		if err != nil {
+			return fmt.Errorf("message: %w", err)
+		}
+		notification.Message = messagePrefix + message
+		if !strings.Contains(message, notification.Highlight) {
+			notification.Highlight = ""
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildNotificationTemplatesSource(t *testing.T) {
+	c := Config{NotifyOnJoin: true}
+	var err error
+	if c.titleTemplates, err = parseEventTemplates(map[string]string{EventJoin: "{{.Source}}: {{.Player}} joined"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.messageTemplates, err = parseEventTemplates(map[string]string{EventJoin: "{{.Player}} joined on {{.Source}}"}); err != nil {
+		t.Fatal(err)
+	}
+	notification := buildNotification(c, LogLine{Time: time.Now(), Code: CodePartyChange, Line: "Kaiyoko Star joins the party.", Source: "laptop"})
+	if notification == nil {
+		t.Fatal("expected a join notification, got none")
+	}
+	if notification.Title != "laptop: Kaiyoko Star joined" {
+		t.Errorf("expected the title to include the source, got %q", notification.Title)
+	}
+	if notification.Message != "Kaiyoko Star joined on laptop" {
+		t.Errorf("expected the message to include the source, got %q", notification.Message)
+	}
+}
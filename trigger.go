@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+const EventTrigger = "trigger"
+
+// Trigger is a user defined rule that sends a notification when a log line
+// matches its pattern.
+type Trigger struct {
+	// Name is used as the event type, defaulting to trigger.
+	Name string `yaml:"name"`
+	// Code is the log code to match, zero matches every code.
+	Code int64 `yaml:"code"`
+	// Channel, one of the chatChannels names, limits matching to its codes.
+	Channel string `yaml:"channel"`
+	Pattern string `yaml:"pattern"`
+	Title   string `yaml:"title"`
+	// Message may reference capture groups as $1 or ${name}, it defaults to
+	// the whole log line.
+	Message string `yaml:"message"`
+	Sound   string `yaml:"sound"`
+
+	pattern *regexp.Regexp
+}
+
+// compileTriggers compiles the pattern of every trigger.
+func compileTriggers(triggers []Trigger) error {
+	for i := range triggers {
+		pattern, err := regexp.Compile(triggers[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for trigger %d: %w", i+1, err)
+		}
+		triggers[i].pattern = pattern
+		if _, ok := chatChannels[triggers[i].Channel]; triggers[i].Channel != "" && !ok {
+			return fmt.Errorf("trigger %d has unknown channel %s", i+1, triggers[i].Channel)
+		}
+		if triggers[i].Name == "" {
+			triggers[i].Name = EventTrigger
+		}
+	}
+	return nil
+}
+
+// buildTriggerNotification returns a notification for the first trigger that
+// matches the log line.
+func buildTriggerNotification(c Config, logLine LogLine) *Notification {
+	for _, trigger := range c.Triggers {
+		if trigger.Code != 0 && trigger.Code != logLine.Code {
+			continue
+		}
+		if trigger.Channel != "" && !slices.Contains(chatChannels[trigger.Channel], logLine.Code) {
+			continue
+		}
+		match := trigger.pattern.FindStringSubmatchIndex(logLine.Line)
+		if match == nil {
+			continue
+		}
+		message := logLine.Line
+		if trigger.Message != "" {
+			message = string(trigger.pattern.ExpandString(nil, trigger.Message, logLine.Line, match))
+		}
+		return newNotification(c, trigger.Name, trigger.Title, message, trigger.Sound)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version and commit are set at build time with
+// -ldflags "-X main.version=1.0.0 -X main.commit=abc1234"
+var version = "dev"
+var commit = ""
+
+// versionString describes the build, falling back to the VCS revision that
+// Go embeds when the commit wasn't set at build time.
+func versionString() string {
+	buildCommit := commit
+	if buildCommit == "" {
+		buildCommit = "unknown"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					buildCommit = setting.Value
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("xiv_party_notification %s (commit %s, %s)", version, buildCommit, runtime.Version())
+}
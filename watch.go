@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadOnHangup reloads the config whenever SIGHUP is received, for when
+// watching the file is unreliable, e.g. a mounted config map.
+func reloadOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		if err := loadConfig(); err != nil {
+			slog.Error("Unable to reload config, keeping the last good config", "error", err)
+			continue
+		}
+		slog.Info("Config reloaded on SIGHUP")
+	}
+}
+
+// watchConfig reloads the config whenever the config file, or a file in the
+// config directory, changes. The directory is watched rather than the file
+// itself as many editors save by replacing the file.
+func watchConfig() {
+	configLock.RLock()
+	configDir := resolveConfigPath(config.ConfigDir)
+	configLock.RUnlock()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("Unable to watch config for changes", "error", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		slog.Warn("Unable to watch config for changes", "error", err)
+		return
+	}
+	if configDir != "" {
+		if err := watcher.Add(configDir); err != nil {
+			slog.Warn("Unable to watch config directory for changes", "path", configDir, "error", err)
+		}
+	}
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			inConfigDir := configDir != "" && filepath.Dir(filepath.Clean(event.Name)) == filepath.Clean(configDir)
+			if filepath.Clean(event.Name) != filepath.Clean(configPath) && !inConfigDir {
+				continue
+			}
+			if !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || (inConfigDir && event.Has(fsnotify.Remove))) {
+				continue
+			}
+			if err := loadConfig(); err != nil {
+				slog.Error("Unable to reload config, keeping the last good config", "error", err)
+				continue
+			}
+			slog.Info("Config reloaded")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("Error watching config", "error", err)
+		}
+	}
+}
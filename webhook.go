@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+)
+
+const defaultWebhookTemplate = `{"title":{{json .Title}},"message":{{json .Message}},"sound":{{json .Sound}}}`
+const defaultWebhookContentType = "application/json"
+
+// webhookTemplateFuncs are available to webhook templates, json allows values
+// to be safely embedded in a JSON document.
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		out, err := json.Marshal(value)
+		return string(out), err
+	},
+}
+
+// parseWebhookTemplate parses the template and renders it once against an
+// empty notification so that references to unknown fields are caught early.
+func parseWebhookTemplate(input string) (*template.Template, error) {
+	if input == "" {
+		input = defaultWebhookTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, &Notification{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+type WebhookNotifier struct {
+	URL         string
+	ContentType string
+	Template    *template.Template
+	// Secret, when set, signs the body in the X-Signature header.
+	Secret string
+}
+
+// webhookSignature returns the GitHub style HMAC-SHA256 signature of the body,
+// e.g. sha256=abc123.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, notification *Notification) error {
+	body := bytes.Buffer{}
+	if err := n.Template.Execute(&body, notification); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", n.ContentType)
+	if n.Secret != "" {
+		req.Header.Set("X-Signature", webhookSignature(n.Secret, body.Bytes()))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	return nil
+}